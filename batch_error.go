@@ -0,0 +1,134 @@
+package egobatch
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yyle88/egobatch/internal/constraint"
+)
+
+// batchErrorShowCount limits failures shown in BatchError summary text
+// batchErrorShowCount 限制 BatchError 摘要文本中展示的失败数量
+const batchErrorShowCount = 3
+
+// BatchErrorItem represents one failed task with index, argument and error
+// BatchErrorItem 代表一个失败的任务，包含序号、参数和错误
+type BatchErrorItem[A any, E ErrorType] struct {
+	Idx int // Task index in source list // 任务在源列表中的序号
+	Arg A   // Task input argument // 任务输入参数
+	Erx E   // Task error (never zero) // 任务错误（不会是零值）
+}
+
+// BatchError aggregates failed tasks into one standard error value
+// Implements Unwrap() []error so errors.Is/As match against each typed error
+// Holds just non-zero errors, so errors.Is never invokes Is method on nil pointer (see constraint.Pass)
+//
+// BatchError 将失败的任务聚合成一个标准 error 值
+// 实现 Unwrap() []error 使 errors.Is/As 可以匹配每个带类型的错误
+// 仅保存非零错误，因此 errors.Is 不会在 nil 指针上调用 Is 方法（参见 constraint.Pass）
+type BatchError[A any, E ErrorType] struct {
+	Items []*BatchErrorItem[A, E] // Failed tasks in source order // 按源顺序排列的失败任务
+	Total int                     // Task count in source list // 源列表中的任务数量
+}
+
+// NewBatchError creates batch error from tasks, picking tasks with non-zero error
+// Index refers to position in given tasks, pass complete tasks to keep batch index
+// Returns nil when no task failed
+//
+// NewBatchError 从任务集合创建批量错误，挑选错误非零的任务
+// 序号指给定任务集合中的位置，传入完整任务集合可保留批量中的序号
+// 没有任务失败时返回 nil
+func NewBatchError[A any, R any, E ErrorType](tasks Tasks[A, R, E]) *BatchError[A, E] {
+	var items []*BatchErrorItem[A, E]
+	for idx, task := range tasks {
+		if !constraint.Pass(task.Erx) {
+			items = append(items, &BatchErrorItem[A, E]{Idx: idx, Arg: task.Arg, Erx: task.Erx})
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return &BatchError[A, E]{Items: items, Total: len(tasks)}
+}
+
+// NewBatchErrorFromOutputs creates batch error from task outputs, picking outputs with non-zero error
+// Returns nil when no output failed
+//
+// NewBatchErrorFromOutputs 从任务输出集合创建批量错误，挑选错误非零的输出
+// 没有输出失败时返回 nil
+func NewBatchErrorFromOutputs[A any, R any, E ErrorType](outputs TaskOutputList[A, R, E]) *BatchError[A, E] {
+	var items []*BatchErrorItem[A, E]
+	for idx, one := range outputs {
+		if !constraint.Pass(one.Erx) {
+			items = append(items, &BatchErrorItem[A, E]{Idx: idx, Arg: one.Arg, Erx: one.Erx})
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return &BatchError[A, E]{Items: items, Total: len(outputs)}
+}
+
+// Error returns readable summary with failure count and leading failures
+// Error 返回包含失败数量和前几个失败的可读摘要
+func (b *BatchError[A, E]) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d of %d tasks failed", len(b.Items), b.Total))
+	for pos, item := range b.Items {
+		if pos >= batchErrorShowCount {
+			sb.WriteString(fmt.Sprintf("; ... (%d more)", len(b.Items)-batchErrorShowCount))
+			break
+		}
+		if pos == 0 {
+			sb.WriteString(": ")
+		} else {
+			sb.WriteString("; ")
+		}
+		sb.WriteString(fmt.Sprintf("[%d] %s", item.Idx, item.Erx.Error()))
+	}
+	return sb.String()
+}
+
+// Unwrap returns each typed error as standard error, enabling errors.Is/As
+// Unwrap 将每个带类型的错误作为标准 error 返回，支持 errors.Is/As
+func (b *BatchError[A, E]) Unwrap() []error {
+	errs := make([]error, 0, len(b.Items))
+	for _, item := range b.Items {
+		errs = append(errs, item.Erx)
+	}
+	return errs
+}
+
+// Reasons returns typed errors in source order
+// Reasons 按源顺序返回带类型的错误
+func (b *BatchError[A, E]) Reasons() []E {
+	reasons := make([]E, 0, len(b.Items))
+	for _, item := range b.Items {
+		reasons = append(reasons, item.Erx)
+	}
+	return reasons
+}
+
+// WaError returns failed tasks as standard error, true nil when no task failed
+// Avoids returning typed nil pointer inside error interface
+//
+// WaError 将失败的任务作为标准 error 返回，没有失败时返回真正的 nil
+// 避免在 error 接口中返回带类型的 nil 指针
+func (tasks Tasks[A, R, E]) WaError() error {
+	if batchError := NewBatchError(tasks); batchError != nil {
+		return batchError
+	}
+	return nil
+}
+
+// WaError returns failed outputs as standard error, true nil when no output failed
+// Avoids returning typed nil pointer inside error interface
+//
+// WaError 将失败的输出作为标准 error 返回，没有失败时返回真正的 nil
+// 避免在 error 接口中返回带类型的 nil 指针
+func (rs TaskOutputList[ARG, RES, E]) WaError() error {
+	if batchError := NewBatchErrorFromOutputs(rs); batchError != nil {
+		return batchError
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package egobatch_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestNewBatchError(t *testing.T) {
+	var tasks = make(egobatch.Tasks[uint64, string, *myerrors.Error], 0, 10)
+	for idx := 0; idx < 10; idx++ {
+		if idx%2 == 0 {
+			tasks = append(tasks, &egobatch.Task[uint64, string, *myerrors.Error]{Arg: uint64(idx), Res: strconv.Itoa(idx)})
+		} else {
+			tasks = append(tasks, &egobatch.Task[uint64, string, *myerrors.Error]{Arg: uint64(idx), Erx: myerrors.ErrorServiceError("wrong-db-%d", idx)})
+		}
+	}
+
+	batchError := egobatch.NewBatchError(tasks)
+	require.NotNil(t, batchError)
+	t.Log(batchError.Error())
+	require.Len(t, batchError.Items, 5)
+	require.Equal(t, 3, batchError.Items[1].Idx)
+	require.Equal(t, uint64(3), batchError.Items[1].Arg)
+	require.Equal(t, "5 of 10 tasks failed: [1] [SERVICE_ERROR] wrong-db-1; [3] [SERVICE_ERROR] wrong-db-3; [5] [SERVICE_ERROR] wrong-db-5; ... (2 more)", batchError.Error())
+	require.Len(t, batchError.Reasons(), 5)
+
+	var err error = batchError
+	require.True(t, errors.Is(err, tasks[7].Erx))
+	var erx *myerrors.Error
+	require.True(t, errors.As(err, &erx))
+	require.Equal(t, tasks[1].Erx, erx)
+	require.True(t, myerrors.IsServiceError(err))
+
+	require.Nil(t, egobatch.NewBatchError(tasks.OkTasks()))
+	require.NoError(t, tasks.OkTasks().WaError())
+	require.Error(t, tasks.WaError())
+}
+
+func TestNewBatchErrorFromOutputs(t *testing.T) {
+	outputs := egobatch.TaskOutputList[int, string, *myerrors.Error]{
+		egobatch.NewOkTaskOutput[int, string, *myerrors.Error](0, "0"),
+		egobatch.NewWaTaskOutput[int, string, *myerrors.Error](1, myerrors.ErrorWrongContext("timeout")),
+	}
+
+	batchError := egobatch.NewBatchErrorFromOutputs(outputs)
+	require.NotNil(t, batchError)
+	require.Equal(t, "1 of 2 tasks failed: [1] [CONTEXT_ERROR] timeout", batchError.Error())
+	require.True(t, myerrors.IsWrongContext(outputs.WaError()))
+	require.NoError(t, outputs.OkList().WaError())
+}
@@ -0,0 +1,335 @@
+package egobatch
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yyle88/egobatch/erxgroup"
+)
+
+// BulkheadStats reports saturation metrics on one key
+// BulkheadStats 报告单个键的饱和度指标
+type BulkheadStats struct {
+	Running   int           // Tasks holding key slot now // 当前占用键槽位的任务数
+	Peak      int           // Max tasks holding key slot at once // 同时占用键槽位的最大任务数
+	Acquired  int           // Tasks that acquired key slot // 获取过键槽位的任务数
+	Saturated int           // Tasks finding key at capacity and waiting // 发现键已满而等待的任务数
+	WaitTime  time.Duration // Time spent waiting on key slot and key rate limit // 等待键槽位和键限流的总时长
+}
+
+// Bulkhead caps concurrency and rate per key, layered on top of global group limit
+// Keeps one slow downstream host or tenant from taking every global slot
+//
+// Bulkhead 按键限制并发数和速率，叠加在全局 group 限制之上
+// 避免单个缓慢的下游主机或租户占用全部全局槽位
+type Bulkhead[K comparable] struct {
+	maxPerKey int     // Concurrency cap per key, non-positive means no cap // 每个键的并发上限，非正数表示不限制
+	rate      float64 // Rate per key per second, non-positive means no rate limit // 每个键每秒的速率，非正数表示不限流
+	burst     int     // Burst per key on rate limit // 每个键的限流突发容量
+
+	mutex sync.Mutex         // Guards keys and freed // 保护键表和 freed
+	keys  map[K]*bulkheadKey // State by key // 按键保存的状态
+	freed chan struct{}      // Closed and replaced when any key slot gets released // 任意键槽位释放时关闭并替换
+}
+
+// bulkheadKey holds slots, limiter and metrics on one key
+// bulkheadKey 保存单个键的槽位、限流器和指标
+type bulkheadKey struct {
+	slots   chan struct{}     // Concurrency slots, nil means no cap // 并发槽位，nil 表示不限制
+	limiter *erxgroup.Limiter // Rate limiter, nil means no rate limit // 限流器，nil 表示不限流
+	stats   BulkheadStats     // Saturation metrics // 饱和度指标
+}
+
+// NewBulkhead creates bulkhead capping concurrency per key
+// NewBulkhead 创建按键限制并发数的隔板
+func NewBulkhead[K comparable](maxPerKey int) *Bulkhead[K] {
+	return &Bulkhead[K]{
+		maxPerKey: maxPerKey,
+		keys:      map[K]*bulkheadKey{},
+		freed:     make(chan struct{}),
+	}
+}
+
+// SetRateLimit configures per-key token-bucket rate limit
+// Must be invoked before first task runs
+//
+// SetRateLimit 配置按键的令牌桶限流
+// 必须在第一个任务执行之前调用
+func (b *Bulkhead[K]) SetRateLimit(rate float64, burst int) {
+	b.rate = rate
+	b.burst = burst
+}
+
+// Stats returns saturation metrics by key
+// Stats 返回按键的饱和度指标
+func (b *Bulkhead[K]) Stats() map[K]BulkheadStats {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	stats := make(map[K]BulkheadStats, len(b.keys))
+	for key, state := range b.keys {
+		stats[key] = state.stats
+	}
+	return stats
+}
+
+// state returns state on key, creating it on first use (caller holds mutex)
+// state 返回键的状态，首次使用时创建（调用方持有锁）
+func (b *Bulkhead[K]) state(key K) *bulkheadKey {
+	state, ok := b.keys[key]
+	if !ok {
+		state = &bulkheadKey{}
+		if b.maxPerKey > 0 {
+			state.slots = make(chan struct{}, b.maxPerKey)
+		}
+		if b.rate > 0 {
+			state.limiter = erxgroup.NewLimiter(b.rate, max(b.burst, 1))
+		}
+		b.keys[key] = state
+	}
+	return state
+}
+
+// acquire takes key slot and key rate token, waiting unless context finishes
+// Returns release function (no-op when slot not taken) and waited duration
+//
+// acquire 获取键槽位和键限流令牌，等待直到上下文结束
+// 返回释放函数（未获取槽位时为空操作）和等待时长
+func (b *Bulkhead[K]) acquire(ctx context.Context, key K) (func(), time.Duration) {
+	b.mutex.Lock()
+	state := b.state(key)
+	b.mutex.Unlock()
+
+	startTime := time.Now()
+	if state.slots != nil {
+		select {
+		case state.slots <- struct{}{}:
+		default:
+			b.record(state, func(stats *BulkheadStats) { stats.Saturated++ })
+			select {
+			case state.slots <- struct{}{}:
+			case <-ctx.Done():
+				waited := time.Since(startTime)
+				b.record(state, func(stats *BulkheadStats) { stats.WaitTime += waited })
+				return func() {}, waited
+			}
+		}
+	}
+	b.record(state, admitted)
+	b.waitRate(ctx, state)
+	waited := time.Since(startTime)
+	b.record(state, func(stats *BulkheadStats) { stats.WaitTime += waited })
+	return b.releaser(state), waited
+}
+
+// tryAcquire takes key slot without waiting, reports whether slot got taken
+// Counts saturation when first is true and key is at capacity, so each queued task counts once
+//
+// tryAcquire 不等待地获取键槽位，报告是否获取成功
+// 当 first 为 true 且键已满时计入饱和，使每个排队的任务只计一次
+func (b *Bulkhead[K]) tryAcquire(key K, first bool) (func(), bool) {
+	b.mutex.Lock()
+	state := b.state(key)
+	b.mutex.Unlock()
+
+	if state.slots != nil {
+		select {
+		case state.slots <- struct{}{}:
+		default:
+			if first {
+				b.record(state, func(stats *BulkheadStats) { stats.Saturated++ })
+			}
+			return nil, false
+		}
+	}
+	b.record(state, admitted)
+	return b.releaser(state), true
+}
+
+// wait takes key rate token on slot taken with tryAcquire, returns waited duration
+// wait 在通过 tryAcquire 获取的槽位上获取键限流令牌，返回等待时长
+func (b *Bulkhead[K]) wait(ctx context.Context, key K) time.Duration {
+	b.mutex.Lock()
+	state := b.state(key)
+	b.mutex.Unlock()
+
+	startTime := time.Now()
+	b.waitRate(ctx, state)
+	waited := time.Since(startTime)
+	b.record(state, func(stats *BulkheadStats) { stats.WaitTime += waited })
+	return waited
+}
+
+// waitRate waits on key rate limiter when set
+// waitRate 在设置了键限流器时等待
+func (b *Bulkhead[K]) waitRate(ctx context.Context, state *bulkheadKey) {
+	if state.limiter != nil {
+		_, _ = state.limiter.Wait(ctx) // Context error falls into task context check // 上下文错误交由任务的上下文检查处理
+	}
+}
+
+// releaser creates function freeing key slot and waking dispatchers waiting on freed
+// releaser 创建释放键槽位并唤醒等待 freed 的分发者的函数
+func (b *Bulkhead[K]) releaser(state *bulkheadKey) func() {
+	return func() {
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		state.stats.Running--
+		if state.slots != nil {
+			<-state.slots
+			close(b.freed) // Wakes dispatchers of every batch sharing the bulkhead // 唤醒共享隔板的所有批量的分发者
+			b.freed = make(chan struct{})
+		}
+	}
+}
+
+// admitted counts task taking key slot
+// admitted 统计获取键槽位的任务
+func admitted(stats *BulkheadStats) {
+	stats.Acquired++
+	stats.Running++
+	stats.Peak = max(stats.Peak, stats.Running)
+}
+
+// waitFreed returns channel closed on next key slot release
+// waitFreed 返回在下一次键槽位释放时关闭的通道
+func (b *Bulkhead[K]) waitFreed() <-chan struct{} {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.freed
+}
+
+// record updates metrics on key under mutex
+// record 在锁内更新键的指标
+func (b *Bulkhead[K]) record(state *bulkheadKey, update func(stats *BulkheadStats)) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	update(&state.stats)
+}
+
+// bulkheadGate adapts typed bulkhead onto batch argument type
+// bulkheadGate 将带类型的隔板适配到批量参数类型
+type bulkheadGate[A any] interface {
+	acquire(ctx context.Context, arg A) (func(), time.Duration)
+	tryAcquire(arg A, first bool) (func(), bool)
+	wait(ctx context.Context, arg A) time.Duration
+	waitFreed() <-chan struct{}
+}
+
+// keyedBulkhead extracts key from argument and delegates to bulkhead
+// keyedBulkhead 从参数提取键并委托给隔板
+type keyedBulkhead[A any, K comparable] struct {
+	key      func(arg A) K // Key extractor // 键提取函数
+	bulkhead *Bulkhead[K]  // Typed bulkhead // 带类型的隔板
+}
+
+func (g *keyedBulkhead[A, K]) acquire(ctx context.Context, arg A) (func(), time.Duration) {
+	return g.bulkhead.acquire(ctx, g.key(arg))
+}
+
+func (g *keyedBulkhead[A, K]) tryAcquire(arg A, first bool) (func(), bool) {
+	return g.bulkhead.tryAcquire(g.key(arg), first)
+}
+
+func (g *keyedBulkhead[A, K]) wait(ctx context.Context, arg A) time.Duration {
+	return g.bulkhead.wait(ctx, g.key(arg))
+}
+
+func (g *keyedBulkhead[A, K]) waitFreed() <-chan struct{} {
+	return g.bulkhead.waitFreed()
+}
+
+// SetBulkhead configures per-key concurrency and rate limits on batch
+// EgoRun, EgoStream and EgoSink then dispatch tasks key-aware: a task gets scheduled into the group just after taking a free slot of its key
+// EgoStreamOrdered and lazy EgoSink keep argument order, so a saturated key holds back later tasks, still off the group
+// Tasks scheduled by hand through GetRun wait on key slot inside the goroutine, holding a global slot meanwhile
+// Slots live in the bulkhead, so with bulkhead shared across batches, tasks of a key saturated by any batch
+// still never occupy global group slots while waiting
+// Key rate token gets taken on each task attempt, retries included
+//
+// SetBulkhead 在批量上配置按键的并发和速率限制
+// 此时 EgoRun、EgoStream 和 EgoSink 按键感知地分发任务：任务获取到其键的空闲槽位后才调度到 group 中
+// EgoStreamOrdered 和惰性 EgoSink 保持参数顺序，因此饱和键会阻挡后续任务，但仍在 group 之外等待
+// 通过 GetRun 手动调度的任务在协程内等待键槽位，期间占用一个全局槽位
+// 槽位保存在隔板中，因此隔板在多个批量间共享时，被任意批量占满的键的任务
+// 在等待时仍不会占用全局 group 槽位
+// 每次任务尝试（包括重试）都获取键限流令牌
+func SetBulkhead[A any, R any, E ErrorType, K comparable](t *TaskBatch[A, R, E], key func(arg A) K, bulkhead *Bulkhead[K]) {
+	t.bulkhead = &keyedBulkhead[A, K]{key: key, bulkhead: bulkhead}
+}
+
+// dispatch schedules tasks at given indexes, taking key slot from shared bulkhead state first when bulkhead is set
+// Tasks of saturated keys queue up and get tried again when any slot of the bulkhead gets released
+// Schedule gets release of the key slot, nil when bulkhead is not set
+//
+// dispatch 调度给定序号的任务，设置隔板时先从共享的隔板状态获取键槽位
+// 饱和键的任务排队，并在隔板的任意槽位释放时再次尝试
+// schedule 接收键槽位的释放函数，未设置隔板时为 nil
+func (t *TaskBatch[A, R, E]) dispatch(queued []int, schedule func(idx int, release func())) {
+	if t.bulkhead == nil {
+		for _, idx := range queued {
+			schedule(idx, nil)
+		}
+		return
+	}
+	tried := make(map[int]bool, len(queued))
+	for len(queued) > 0 {
+		freed := t.bulkhead.waitFreed() // Taken before trying, so a release in between is not missed // 在尝试前获取，避免错过其间的释放
+		remaining := queued[:0]
+		for _, idx := range queued {
+			release, ok := t.bulkhead.tryAcquire(t.Tasks[idx].Arg, !tried[idx])
+			if !ok {
+				tried[idx] = true
+				remaining = append(remaining, idx)
+				continue
+			}
+			schedule(idx, release)
+		}
+		queued = remaining
+		if len(queued) > 0 {
+			<-freed
+		}
+	}
+}
+
+// holdKey takes key slot on task in dispatcher, waiting off the group until the slot gets taken
+// Serves schedulers bound to argument order, where a saturated key holds back later tasks
+// Returns nil when bulkhead is not set
+//
+// holdKey 在分发者中获取任务的键槽位，在 group 之外等待直到获取成功
+// 用于受参数顺序约束的调度器，此时饱和键会阻挡后续任务
+// 未设置隔板时返回 nil
+func (t *TaskBatch[A, R, E]) holdKey(task *Task[A, R, E]) func() {
+	if t.bulkhead == nil {
+		return nil
+	}
+	for first := true; ; first = false {
+		freed := t.bulkhead.waitFreed() // Taken before trying, so a release in between is not missed // 在尝试前获取，避免错过其间的释放
+		if release, ok := t.bulkhead.tryAcquire(task.Arg, first); ok {
+			return release
+		}
+		<-freed
+	}
+}
+
+// egoGoKeyed schedules task into group, release when not nil frees key slot taken by dispatcher
+// Slot gets freed once the task settles, before wrap decorations such as publishing run
+//
+// egoGoKeyed 将任务调度到 group 中，release 不为 nil 时释放分发者获取的键槽位
+// 槽位在任务结果确定后释放，早于发布等 wrap 装饰逻辑
+func (t *TaskBatch[A, R, E]) egoGoKeyed(ego *erxgroup.Group[E], task *Task[A, R, E], run func(ctx context.Context, arg A) (R, E), release func(), wrap func(taskRun func(ctx context.Context) E) func(ctx context.Context) E) {
+	if release == nil {
+		t.egoGo(ego, task, t.taskRun(task, run), wrap)
+		return
+	}
+	t.egoGo(ego, task, t.gatedRun(task, run, true), func(taskRun func(ctx context.Context) E) func(ctx context.Context) E {
+		keyRun := func(ctx context.Context) E {
+			defer release() // Slot taken by dispatcher, freed on every path including oversize weight // 槽位由分发者获取，在包括权重超限在内的所有路径上释放
+			return taskRun(ctx)
+		}
+		if wrap != nil {
+			return wrap(keyRun)
+		}
+		return keyRun
+	})
+}
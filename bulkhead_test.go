@@ -0,0 +1,214 @@
+package egobatch_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestSetBulkhead(t *testing.T) {
+	// Args 0..5 hit slow host "a", args 6..11 hit fast host "b"
+	// 参数 0..5 访问慢主机 "a"，参数 6..11 访问快主机 "b"
+	args := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11}
+	hostOf := func(arg int) string {
+		if arg < 6 {
+			return "a"
+		}
+		return "b"
+	}
+	taskBatch := egobatch.NewTaskBatch[int, string, *myerrors.Error](args)
+	bulkhead := egobatch.NewBulkhead[string](2)
+	egobatch.SetBulkhead(taskBatch, hostOf, bulkhead)
+
+	var mutex sync.Mutex
+	var finishOrder []string
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(4)
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg int) (string, *myerrors.Error) {
+		if hostOf(arg) == "a" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		mutex.Lock()
+		finishOrder = append(finishOrder, hostOf(arg))
+		mutex.Unlock()
+		return hostOf(arg), nil
+	})
+	require.Nil(t, ego.Wait())
+	require.Len(t, taskBatch.Tasks.OkTasks(), len(args))
+
+	// Slow key holds at most 2 global slots, so fast key finishes first
+	// 慢键最多占用 2 个全局槽位，因此快键先完成
+	require.Equal(t, []string{"b", "b", "b", "b", "b", "b"}, finishOrder[:6])
+
+	stats := bulkhead.Stats()
+	require.Len(t, stats, 2)
+	for _, key := range []string{"a", "b"} {
+		require.Equal(t, 6, stats[key].Acquired)
+		require.LessOrEqual(t, stats[key].Peak, 2)
+		require.Equal(t, 0, stats[key].Running)
+	}
+	require.Equal(t, 2, stats["a"].Peak)
+}
+
+func TestBulkhead_SetRateLimit(t *testing.T) {
+	args := []int{0, 1, 2, 3, 4, 5}
+	taskBatch := egobatch.NewTaskBatch[int, int, *myerrors.Error](args)
+	bulkhead := egobatch.NewBulkhead[int](0) // No concurrency cap, just rate // 不限制并发，仅限流
+	bulkhead.SetRateLimit(50, 1)
+	egobatch.SetBulkhead(taskBatch, func(arg int) int { return arg % 2 }, bulkhead)
+
+	startTime := time.Now()
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg int) (int, *myerrors.Error) {
+		return arg, nil
+	})
+	require.Nil(t, ego.Wait())
+	// Each key runs 3 tasks at 50/s with burst 1: at least 2 intervals of 20ms
+	// 每个键以 50/s、突发 1 执行 3 个任务：至少 2 个 20ms 间隔
+	require.GreaterOrEqual(t, time.Since(startTime), 35*time.Millisecond)
+
+	stats := bulkhead.Stats()
+	for _, key := range []int{0, 1} {
+		require.Equal(t, 3, stats[key].Acquired)
+		require.Equal(t, 0, stats[key].Saturated)
+		require.Greater(t, stats[key].WaitTime, time.Duration(0))
+	}
+	for _, task := range taskBatch.Tasks {
+		require.Equal(t, egobatch.TaskStatusSucceeded, task.Status)
+	}
+}
+
+func TestBulkhead_SetRateLimit_Retry(t *testing.T) {
+	taskBatch := egobatch.NewTaskBatch[int, int, *myerrors.Error]([]int{0})
+	taskBatch.SetRetry(egobatch.NewRetryPolicy[*myerrors.Error](4, 0))
+	bulkhead := egobatch.NewBulkhead[int](1)
+	bulkhead.SetRateLimit(50, 1)
+	egobatch.SetBulkhead(taskBatch, func(arg int) int { return arg }, bulkhead)
+
+	var calls int
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg int) (int, *myerrors.Error) {
+		calls++
+		return 0, myerrors.ErrorServiceError("busy")
+	})
+	require.NotNil(t, ego.Wait())
+	require.Equal(t, 4, calls)
+
+	// Each retry takes own key rate token at 50/s with burst 1: at least 3 intervals of 20ms
+	// 每次重试以 50/s、突发 1 获取自己的键限流令牌：至少 3 个 20ms 间隔
+	task := taskBatch.Tasks[0]
+	require.Equal(t, 4, task.Attempts)
+	require.GreaterOrEqual(t, task.Waited, 55*time.Millisecond)
+	stats := bulkhead.Stats()
+	require.Equal(t, 1, stats[0].Acquired) // Slot held across retries // 槽位在重试间保持持有
+	require.GreaterOrEqual(t, stats[0].WaitTime, 55*time.Millisecond)
+}
+
+func TestBulkhead_Saturated(t *testing.T) {
+	// EgoStream dispatches key-aware, so tasks past the key cap queue up off the group
+	// EgoStream 按键感知分发，因此超过键上限的任务在 group 之外排队
+	args := []int{0, 1, 2, 3}
+	taskBatch := egobatch.NewTaskBatch[int, int, *myerrors.Error](args)
+	bulkhead := egobatch.NewBulkhead[string](1)
+	egobatch.SetBulkhead(taskBatch, func(arg int) string { return "same" }, bulkhead)
+
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	var count int
+	for _, task := range taskBatch.EgoStream(ego, func(ctx context.Context, arg int) (int, *myerrors.Error) {
+		time.Sleep(10 * time.Millisecond)
+		return arg, nil
+	}, len(args)) {
+		require.Equal(t, egobatch.TaskStatusSucceeded, task.Status)
+		count++
+	}
+	require.Nil(t, ego.Wait())
+	require.Equal(t, len(args), count)
+
+	stats := bulkhead.Stats()["same"]
+	require.Equal(t, 1, stats.Peak)
+	require.Equal(t, 4, stats.Acquired)
+	require.Equal(t, 3, stats.Saturated)
+	require.Equal(t, 0, stats.Running)
+}
+
+// bulkheadSchedule schedules batch into group with one of the key-aware schedulers
+// bulkheadSchedule 使用某个按键感知的调度器将批量调度到 group 中
+type bulkheadSchedule func(taskBatch *egobatch.TaskBatch[string, string, *myerrors.Error], ego *erxgroup.Group[*myerrors.Error], run func(ctx context.Context, arg string) (string, *myerrors.Error))
+
+func TestSetBulkhead_Shared(t *testing.T) {
+	schedules := map[string]bulkheadSchedule{
+		"EgoRun": func(taskBatch *egobatch.TaskBatch[string, string, *myerrors.Error], ego *erxgroup.Group[*myerrors.Error], run func(ctx context.Context, arg string) (string, *myerrors.Error)) {
+			taskBatch.EgoRun(ego, run)
+		},
+		"EgoStream": func(taskBatch *egobatch.TaskBatch[string, string, *myerrors.Error], ego *erxgroup.Group[*myerrors.Error], run func(ctx context.Context, arg string) (string, *myerrors.Error)) {
+			for range taskBatch.EgoStream(ego, run, 0) {
+			}
+		},
+		"EgoSink": func(taskBatch *egobatch.TaskBatch[string, string, *myerrors.Error], ego *erxgroup.Group[*myerrors.Error], run func(ctx context.Context, arg string) (string, *myerrors.Error)) {
+			taskBatch.EgoSink(ego, run, func(idx int, task *egobatch.Task[string, string, *myerrors.Error]) {})
+		},
+	}
+	for name, scheduleB := range schedules {
+		t.Run(name, func(t *testing.T) {
+			testSetBulkheadShared(t, scheduleB)
+		})
+	}
+}
+
+func testSetBulkheadShared(t *testing.T, scheduleB bulkheadSchedule) {
+	// Batch A holds the only slot of key "a" until batch B finishes its "b" task
+	// 批量 A 占用键 "a" 的唯一槽位，直到批量 B 完成其 "b" 任务
+	bulkhead := egobatch.NewBulkhead[string](1)
+	keyOf := func(arg string) string { return arg }
+
+	bDone := make(chan struct{})
+	aStarted := make(chan struct{})
+	batchA := egobatch.NewTaskBatch[string, string, *myerrors.Error]([]string{"a"})
+	egobatch.SetBulkhead(batchA, keyOf, bulkhead)
+	egoA := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	batchA.EgoRun(egoA, func(ctx context.Context, arg string) (string, *myerrors.Error) {
+		close(aStarted)
+		select {
+		case <-bDone:
+			return arg, nil
+		case <-time.After(time.Second):
+			return "", myerrors.ErrorServiceError("wrong-%s", arg)
+		}
+	})
+	<-aStarted
+
+	// Batch B has one global slot: its "a" task must wait off the group, so "b" runs meanwhile
+	// 批量 B 只有一个全局槽位：其 "a" 任务必须在 group 之外等待，因此 "b" 同时执行
+	batchB := egobatch.NewTaskBatch[string, string, *myerrors.Error]([]string{"a", "b"})
+	egobatch.SetBulkhead(batchB, keyOf, bulkhead)
+	egoB := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	egoB.SetLimit(1)
+	var bRuns sync.WaitGroup
+	bRuns.Add(1)
+	go func() {
+		defer bRuns.Done()
+		scheduleB(batchB, egoB, func(ctx context.Context, arg string) (string, *myerrors.Error) {
+			if arg == "b" {
+				close(bDone)
+			}
+			return arg, nil
+		})
+	}()
+
+	require.Nil(t, egoA.Wait())
+	bRuns.Wait()
+	require.Nil(t, egoB.Wait())
+	require.Len(t, batchB.Tasks.OkTasks(), 2)
+
+	stats := bulkhead.Stats()
+	require.Equal(t, 2, stats["a"].Acquired)
+	require.Equal(t, 1, stats["a"].Saturated)
+	require.Equal(t, 1, stats["a"].Peak)
+	require.Equal(t, 0, stats["a"].Running)
+}
@@ -0,0 +1,300 @@
+package egobatch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/yyle88/egobatch/internal/constraint"
+)
+
+// Codec converts values to and from JSON values stored in checkpoint lines
+// Encode must return one valid JSON value, non-JSON formats can be wrapped as JSON string
+//
+// Codec 在值与检查点行中保存的 JSON 值之间转换
+// Encode 必须返回一个合法的 JSON 值，非 JSON 格式可以包装为 JSON 字符串
+type Codec[T any] struct {
+	Encode func(value T) ([]byte, error) // Value to JSON value // 值转 JSON 值
+	Decode func(data []byte) (T, error)  // JSON value to value // JSON 值转值
+}
+
+// NewJSONCodec creates codec using encoding/json
+// NewJSONCodec 创建使用 encoding/json 的编解码器
+func NewJSONCodec[T any]() *Codec[T] {
+	return &Codec[T]{
+		Encode: func(value T) ([]byte, error) {
+			return json.Marshal(value)
+		},
+		Decode: func(data []byte) (T, error) {
+			var value T
+			err := json.Unmarshal(data, &value)
+			return value, err
+		},
+	}
+}
+
+// CheckpointCodecs holds stable argument key and codecs on argument, result and error
+// CheckpointCodecs 保存稳定的参数键以及参数、结果和错误的编解码器
+type CheckpointCodecs[A any, R any, E ErrorType] struct {
+	Key func(arg A) string // Stable argument key matching tasks across runs // 跨执行匹配任务的稳定参数键
+	Arg *Codec[A]          // Argument codec // 参数编解码器
+	Res *Codec[R]          // Result codec // 结果编解码器
+	Erx *Codec[E]          // Error codec // 错误编解码器
+}
+
+// checkpointRecord is one JSONL line holding settled task outcome
+// checkpointRecord 是保存已确定任务结果的一行 JSONL
+type checkpointRecord struct {
+	Key      string          `json:"key"`           // Argument key // 参数键
+	Status   TaskStatus      `json:"status"`        // Task status // 任务状态
+	Arg      json.RawMessage `json:"arg"`           // Encoded argument // 编码后的参数
+	Res      json.RawMessage `json:"res,omitempty"` // Encoded result on success // 成功时编码后的结果
+	Erx      json.RawMessage `json:"erx,omitempty"` // Encoded error on failure // 失败时编码后的错误
+	Attempts int             `json:"attempts"`      // Run invocations // 调用次数
+	Elapsed  time.Duration   `json:"elapsed"`       // Run time in nanoseconds // 以纳秒计的执行时长
+}
+
+// Checkpoint appends settled task outcomes to JSONL file and reloads them on resume
+// Lines get appended as tasks complete, a later line on same key overrides earlier ones
+// A truncated last line left by a killed process gets ignored on reload and cut off
+//
+// Checkpoint 将已确定的任务结果追加到 JSONL 文件，并在恢复时重新加载
+// 任务完成时追加行，同一个键上较后的行覆盖较早的行
+// 重新加载时忽略并截掉被终止进程留下的不完整末行
+type Checkpoint[A any, R any, E ErrorType] struct {
+	codecs *CheckpointCodecs[A, R, E] // Key and codecs // 键和编解码器
+
+	mutex   sync.Mutex                   // Guards fields below // 保护下面的字段
+	file    *os.File                     // File opened in append mode // 以追加模式打开的文件
+	records map[string]*checkpointRecord // Last reloaded record by key // 按键保存的最后加载的记录
+	err     error                        // First write error // 第一个写入错误
+}
+
+// OpenCheckpoint reloads existing lines from path and opens it in append mode, creating it when missing
+// A truncated last line gets cut off, so lines appended next start on a fresh line
+//
+// OpenCheckpoint 从 path 重新加载已有的行并以追加模式打开，不存在时创建
+// 不完整的末行会被截掉，使后续追加的行从新的一行开始
+func OpenCheckpoint[A any, R any, E ErrorType](path string, codecs *CheckpointCodecs[A, R, E]) (*Checkpoint[A, R, E], error) {
+	records, keep, err := readCheckpoint(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := repairCheckpoint(file, keep); err != nil {
+		return nil, errors.Join(err, file.Close())
+	}
+	return &Checkpoint[A, R, E]{
+		codecs:  codecs,
+		file:    file,
+		records: records,
+	}, nil
+}
+
+// repairCheckpoint cuts file back to keep bytes and ends it with newline when content is left
+// repairCheckpoint 将文件截回到 keep 字节，有内容时确保以换行符结尾
+func repairCheckpoint(file *os.File, keep []byte) error {
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if stat.Size() > int64(len(keep)) {
+		if err := file.Truncate(int64(len(keep))); err != nil {
+			return err
+		}
+	}
+	if len(keep) > 0 && keep[len(keep)-1] != '\n' {
+		_, err = file.Write([]byte{'\n'}) // Complete last line without newline // 没有换行符的完整末行
+	}
+	return err
+}
+
+// readCheckpoint reads records by key, ignoring truncated last line
+// Returns file content up to the end of the last complete line
+//
+// readCheckpoint 按键读取记录，忽略不完整的末行
+// 返回截至最后一个完整行末尾的文件内容
+func readCheckpoint(path string) (map[string]*checkpointRecord, []byte, error) {
+	records := map[string]*checkpointRecord{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return records, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := bufio.NewReader(bytes.NewReader(data))
+	offset := 0
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				var record checkpointRecord
+				if json.Unmarshal(line, &record) != nil {
+					return records, data[:offset], nil // Truncated last line left by killed process // 被终止进程留下的不完整末行
+				}
+				records[record.Key] = &record
+			}
+			return records, data, nil
+		}
+		offset += len(line)
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var record checkpointRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, nil, fmt.Errorf("checkpoint %s line %d: %w", path, lineNum, err)
+		}
+		records[record.Key] = &record
+	}
+}
+
+// Completed returns count of reloaded keys whose last outcome succeeded
+// Completed 返回重新加载的键中最后结果为成功的数量
+func (c *Checkpoint[A, R, E]) Completed() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	count := 0
+	for _, record := range c.records {
+		if record.Status == TaskStatusSucceeded {
+			count++
+		}
+	}
+	return count
+}
+
+// restore fills task from reloaded record when its last outcome succeeded
+// restore 当重新加载的记录最后结果为成功时填充任务
+func (c *Checkpoint[A, R, E]) restore(task *Task[A, R, E]) error {
+	c.mutex.Lock()
+	record, ok := c.records[c.codecs.Key(task.Arg)]
+	c.mutex.Unlock()
+	if !ok || record.Status != TaskStatusSucceeded {
+		return nil
+	}
+	res, err := c.codecs.Res.Decode(record.Res)
+	if err != nil {
+		return fmt.Errorf("checkpoint key %s: %w", record.Key, err)
+	}
+	task.Res = res
+	task.Status = TaskStatusSucceeded
+	task.Attempts = record.Attempts
+	task.Elapsed = record.Elapsed
+	return nil
+}
+
+// record appends settled task outcome as one line, tasks never executed get no line
+// Write errors get kept and reported by Err and Close
+//
+// record 将已确定的任务结果追加为一行，从未执行的任务不写入
+// 写入错误会被保存，并由 Err 和 Close 报告
+func (c *Checkpoint[A, R, E]) record(task *Task[A, R, E]) {
+	if !task.Status.Executed() {
+		return
+	}
+	line, err := c.encode(task)
+	if err == nil {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+		_, err = c.file.Write(line) // One write per line keeps lines whole across concurrent tasks // 每行一次写入，使并发任务的行保持完整
+	}
+	if err != nil {
+		c.fail(err)
+	}
+}
+
+// encode builds JSONL line on task
+// encode 构建任务对应的 JSONL 行
+func (c *Checkpoint[A, R, E]) encode(task *Task[A, R, E]) ([]byte, error) {
+	record := &checkpointRecord{
+		Key:      c.codecs.Key(task.Arg),
+		Status:   task.Status,
+		Attempts: task.Attempts,
+		Elapsed:  task.Elapsed,
+	}
+	var err error
+	if record.Arg, err = c.codecs.Arg.Encode(task.Arg); err != nil {
+		return nil, err
+	}
+	if task.Status == TaskStatusSucceeded {
+		if record.Res, err = c.codecs.Res.Encode(task.Res); err != nil {
+			return nil, err
+		}
+	}
+	if !constraint.Pass(task.Erx) {
+		if record.Erx, err = c.codecs.Erx.Encode(task.Erx); err != nil {
+			return nil, err
+		}
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// fail keeps first write error
+// fail 保存第一个写入错误
+func (c *Checkpoint[A, R, E]) fail(err error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+// Err returns first write error, nil when every line got written
+// Err 返回第一个写入错误，所有行都写入成功时为 nil
+func (c *Checkpoint[A, R, E]) Err() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.err
+}
+
+// Close syncs and closes the file, returns first write error when any
+// Close 同步并关闭文件，存在写入错误时返回第一个写入错误
+func (c *Checkpoint[A, R, E]) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	err := errors.Join(c.file.Sync(), c.file.Close())
+	if c.err != nil {
+		return c.err
+	}
+	return err
+}
+
+// SetCheckpoint appends outcome of each settled task to checkpoint as tasks complete
+// Tasks already succeeded when scheduled, as restored by NewTaskBatchResume, get skipped
+//
+// SetCheckpoint 在任务完成时将每个已确定任务的结果追加到检查点
+// 调度时已成功的任务（由 NewTaskBatchResume 恢复）会被跳过
+func (t *TaskBatch[A, R, E]) SetCheckpoint(checkpoint *Checkpoint[A, R, E]) {
+	t.checkpoint = checkpoint
+}
+
+// NewTaskBatchResume creates batch restoring succeeded arguments from checkpoint
+// Restored tasks hold decoded results and get skipped, pending and failed ones run again
+// Tasks keep argument order so results map back to their original index
+//
+// NewTaskBatchResume 创建从检查点恢复已成功参数的批量
+// 恢复的任务保存解码后的结果并被跳过，等待中和失败的任务会重新执行
+// 任务保持参数顺序，因此结果对应到原来的序号
+func NewTaskBatchResume[A any, R any, E ErrorType](args []A, checkpoint *Checkpoint[A, R, E]) (*TaskBatch[A, R, E], error) {
+	taskBatch := NewTaskBatch[A, R, E](args)
+	for _, task := range taskBatch.Tasks {
+		if err := checkpoint.restore(task); err != nil {
+			return nil, err
+		}
+	}
+	taskBatch.SetCheckpoint(checkpoint)
+	return taskBatch, nil
+}
@@ -0,0 +1,162 @@
+package egobatch_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+// newCheckpointCodecs creates codecs keeping error message as JSON string
+// newCheckpointCodecs 创建将错误消息保存为 JSON 字符串的编解码器
+func newCheckpointCodecs() *egobatch.CheckpointCodecs[int, string, *myerrors.Error] {
+	return &egobatch.CheckpointCodecs[int, string, *myerrors.Error]{
+		Key: strconv.Itoa,
+		Arg: egobatch.NewJSONCodec[int](),
+		Res: egobatch.NewJSONCodec[string](),
+		Erx: &egobatch.Codec[*myerrors.Error]{
+			Encode: func(erx *myerrors.Error) ([]byte, error) {
+				return json.Marshal(erx.Error())
+			},
+			Decode: func(data []byte) (*myerrors.Error, error) {
+				var message string
+				if err := json.Unmarshal(data, &message); err != nil {
+					return nil, err
+				}
+				return myerrors.ErrorServiceError("%s", message), nil
+			},
+		},
+	}
+}
+
+func TestNewTaskBatchResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.jsonl")
+	args := []int{0, 1, 2, 3, 4, 5}
+
+	// First run: odd arguments fail
+	// 第一次执行：奇数参数失败
+	checkpoint, err := egobatch.OpenCheckpoint(path, newCheckpointCodecs())
+	require.NoError(t, err)
+	taskBatch, err := egobatch.NewTaskBatchResume(args, checkpoint)
+	require.NoError(t, err)
+	taskBatch.SetGlide(true)
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg int) (string, *myerrors.Error) {
+		if arg%2 == 1 {
+			return "", myerrors.ErrorServiceError("wrong-%d", arg)
+		}
+		return "ok-" + strconv.Itoa(arg), nil
+	})
+	myassert.NoError(t, ego.Wait())
+	require.NoError(t, checkpoint.Close())
+
+	// Resume: only failed arguments run again, restored results keep their index
+	// 恢复：只有失败的参数再次执行，恢复的结果保持原来的序号
+	checkpoint, err = egobatch.OpenCheckpoint(path, newCheckpointCodecs())
+	require.NoError(t, err)
+	require.Equal(t, 3, checkpoint.Completed())
+	taskBatch, err = egobatch.NewTaskBatchResume(args, checkpoint)
+	require.NoError(t, err)
+	require.Equal(t, egobatch.TaskStatusSucceeded, taskBatch.Tasks[2].Status)
+	require.Equal(t, "ok-2", taskBatch.Tasks[2].Res)
+	require.Equal(t, egobatch.TaskStatusPending, taskBatch.Tasks[3].Status)
+
+	var runs, evenRuns atomic.Int32
+	taskBatch.SetGlide(true)
+	ego = erxgroup.NewGroup[*myerrors.Error](context.Background())
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg int) (string, *myerrors.Error) {
+		runs.Add(1)
+		if arg%2 == 0 {
+			evenRuns.Add(1)
+		}
+		return "ok-" + strconv.Itoa(arg), nil
+	})
+	myassert.NoError(t, ego.Wait())
+	require.NoError(t, checkpoint.Close())
+	require.Equal(t, int32(3), runs.Load())
+	require.Equal(t, int32(0), evenRuns.Load())
+	for idx, task := range taskBatch.Tasks {
+		require.Equal(t, egobatch.TaskStatusSucceeded, task.Status)
+		require.Equal(t, "ok-"+strconv.Itoa(idx), task.Res)
+	}
+
+	// Third open: every argument completed, later lines override failed ones
+	// 第三次打开：所有参数都已完成，较后的行覆盖失败的行
+	checkpoint, err = egobatch.OpenCheckpoint(path, newCheckpointCodecs())
+	require.NoError(t, err)
+	require.Equal(t, len(args), checkpoint.Completed())
+	require.NoError(t, checkpoint.Close())
+}
+
+func TestOpenCheckpoint_TruncatedLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.jsonl")
+	content := `{"key":"0","status":"SUCCEEDED","arg":0,"res":"ok-0","attempts":1,"elapsed":0}` + "\n" +
+		`{"key":"1","status":"SUCC` // Process killed while writing // 写入时进程被终止
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	checkpoint, err := egobatch.OpenCheckpoint(path, newCheckpointCodecs())
+	require.NoError(t, err)
+	require.Equal(t, 1, checkpoint.Completed())
+
+	taskBatch, err := egobatch.NewTaskBatchResume([]int{0, 1}, checkpoint)
+	require.NoError(t, err)
+	require.Equal(t, egobatch.TaskStatusSucceeded, taskBatch.Tasks[0].Status)
+	require.Equal(t, "ok-0", taskBatch.Tasks[0].Res)
+	require.Equal(t, egobatch.TaskStatusPending, taskBatch.Tasks[1].Status)
+
+	// Resume writes after the cut line, so the file reloads again
+	// 恢复后的写入位于截掉的行之后，因此文件可以再次加载
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg int) (string, *myerrors.Error) {
+		return "ok-" + strconv.Itoa(arg), nil
+	})
+	myassert.NoError(t, ego.Wait())
+	require.NoError(t, checkpoint.Close())
+
+	checkpoint, err = egobatch.OpenCheckpoint(path, newCheckpointCodecs())
+	require.NoError(t, err)
+	require.Equal(t, 2, checkpoint.Completed())
+	require.NoError(t, checkpoint.Close())
+}
+
+func TestOpenCheckpoint_MissingNewline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.jsonl")
+	content := `{"key":"0","status":"SUCCEEDED","arg":0,"res":"ok-0","attempts":1,"elapsed":0}` // Complete line without newline // 没有换行符的完整行
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	checkpoint, err := egobatch.OpenCheckpoint(path, newCheckpointCodecs())
+	require.NoError(t, err)
+	taskBatch, err := egobatch.NewTaskBatchResume([]int{0, 1}, checkpoint)
+	require.NoError(t, err)
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg int) (string, *myerrors.Error) {
+		return "ok-" + strconv.Itoa(arg), nil
+	})
+	myassert.NoError(t, ego.Wait())
+	require.NoError(t, checkpoint.Close())
+
+	checkpoint, err = egobatch.OpenCheckpoint(path, newCheckpointCodecs())
+	require.NoError(t, err)
+	require.Equal(t, 2, checkpoint.Completed())
+	require.NoError(t, checkpoint.Close())
+}
+
+func TestOpenCheckpoint_MalformedLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.jsonl")
+	content := "not-json\n" +
+		`{"key":"0","status":"SUCCEEDED","arg":0,"res":"ok-0","attempts":1,"elapsed":0}` + "\n"
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	_, err := egobatch.OpenCheckpoint(path, newCheckpointCodecs())
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "line 1")
+}
@@ -0,0 +1,238 @@
+package egobatch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/constraint"
+	"github.com/yyle88/egobatch/internal/utils"
+	"github.com/yyle88/must"
+	"github.com/yyle88/must/mustnum"
+)
+
+// ChunkBatch manages batch execution over chunks of arguments targeting bulk APIs
+// Each chunk runs once with its argument slice, results fan back out into per-argument tasks
+// A failed chunk marks each member task with the chunk error, unless fallback runs members one by one
+//
+// ChunkBatch 管理按参数分块的批量执行，面向批量接口
+// 每个分块使用其参数切片执行一次，结果再分发回每个参数对应的任务
+// 分块失败时每个成员任务都记录该分块错误，除非设置了逐个执行成员的回退函数
+type ChunkBatch[A any, R any, E ErrorType] struct {
+	Tasks  Tasks[A, R, E]    // Task collection with arguments and results // 任务集合，包含参数和结果
+	Chunks [][]int           // Task indexes grouped by chunk // 按分块分组的任务序号
+	Glide  bool              // Glide mode flag: false=fail-fast, true=independent chunks // 平滑模式标志：false=快速失败，true=独立分块
+	waCtx  func(err error) E // Context error conversion function // 上下文错误转换函数
+
+	fallback func(ctx context.Context, arg A) (R, E) // Per-argument run on failed chunk, nil means no fallback // 分块失败时逐个参数执行的函数，nil 表示不回退
+	waLen    func(err error) E                       // Result count mismatch error conversion function // 结果数量不一致错误转换函数
+}
+
+// ChunkLenError reports bulk run returning result count different from argument count
+// Bulk multi-get APIs leaving out missing keys cause it routinely
+//
+// ChunkLenError 报告批量执行返回的结果数量与参数数量不一致
+// 省略缺失键的批量查询接口经常导致该错误
+type ChunkLenError struct {
+	Args    int // Argument count of chunk // 分块的参数数量
+	Results int // Result count returned by run // run 返回的结果数量
+}
+
+func (e *ChunkLenError) Error() string {
+	return fmt.Sprintf("egobatch: chunk run returned %d results on %d args", e.Results, e.Args)
+}
+
+// NewChunkBatch creates chunk batch splitting arguments into chunks of given size
+// Last chunk may hold fewer arguments
+//
+// NewChunkBatch 创建按给定大小将参数分块的分块批量
+// 最后一个分块的参数可能较少
+func NewChunkBatch[A any, R any, E ErrorType](args []A, chunkSize int) *ChunkBatch[A, R, E] {
+	mustnum.Positive(chunkSize)
+	var chunks [][]int
+	for start := 0; start < len(args); start += chunkSize {
+		chunk := make([]int, 0, chunkSize)
+		for idx := start; idx < min(start+chunkSize, len(args)); idx++ {
+			chunk = append(chunk, idx)
+		}
+		chunks = append(chunks, chunk)
+	}
+	return newChunkBatch[A, R, E](args, chunks)
+}
+
+// NewChunkBatchWeight creates chunk batch splitting arguments by accumulated weight
+// Arguments get appended in order until adding the next one exceeds maxWeight
+// Argument heavier than maxWeight forms a chunk alone
+//
+// NewChunkBatchWeight 创建按累计权重将参数分块的分块批量
+// 按顺序追加参数，直到追加下一个会超过 maxWeight
+// 权重超过 maxWeight 的参数单独成为一个分块
+func NewChunkBatchWeight[A any, R any, E ErrorType](args []A, maxWeight int64, weight func(arg A) int64) *ChunkBatch[A, R, E] {
+	mustnum.Positive(maxWeight)
+	var chunks [][]int
+	var chunk []int
+	var total int64
+	for idx, arg := range args {
+		value := weight(arg)
+		if len(chunk) > 0 && total+value > maxWeight {
+			chunks = append(chunks, chunk)
+			chunk, total = nil, 0
+		}
+		chunk = append(chunk, idx)
+		total += value
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+	return newChunkBatch[A, R, E](args, chunks)
+}
+
+func newChunkBatch[A any, R any, E ErrorType](args []A, chunks [][]int) *ChunkBatch[A, R, E] {
+	tasks := make([]*Task[A, R, E], 0, len(args))
+	for idx := 0; idx < len(args); idx++ {
+		tasks = append(tasks, newTask[A, R, E](args[idx]))
+	}
+	return &ChunkBatch[A, R, E]{
+		Tasks:  tasks,
+		Chunks: chunks,
+		Glide:  false,
+	}
+}
+
+// GetRun creates execution function on chunk at given index compatible with errgroup.Go
+// Run must return one result per argument in argument order when succeeding
+// Result count mismatch fails the chunk via fallback, else via waLen, one of them must be set before scheduling
+//
+// GetRun 在给定序号的分块上创建与 errgroup.Go 兼容的执行函数
+// run 成功时必须按参数顺序为每个参数返回一个结果
+// 结果数量不一致时分块经回退函数失败，否则经 waLen 失败，调度前必须设置其中之一
+func (c *ChunkBatch[A, R, E]) GetRun(chunkIdx int, run func(ctx context.Context, args []A) ([]R, E)) func(ctx context.Context) E {
+	mustnum.Less(chunkIdx, len(c.Chunks))          // Index bounds check - invoking code must not exceed chunk count // 索引边界检查 - 调用代码不能超过分块数量
+	must.True(c.fallback != nil || c.waLen != nil) // Result count mismatch needs handling, checked here instead of in worker // 结果数量不一致需要处理，在此检查而不是在工作协程中
+	tasks := make(Tasks[A, R, E], 0, len(c.Chunks[chunkIdx]))
+	for _, idx := range c.Chunks[chunkIdx] {
+		tasks = append(tasks, c.Tasks[idx])
+	}
+	return func(ctx context.Context) E {
+		if ctx.Err() != nil {
+			if c.waCtx == nil {
+				tasks.setStatus(TaskStatusSkipped)
+				return c.glideErx(utils.Zero[E]())
+			}
+			erx := c.waCtx(ctx.Err()) // Convert context error - must return valid error, not fake zero // 转换上下文错误 - 必须返回有效错误，不能是伪造的零值
+			must.False(constraint.Pass(erx))
+			tasks.setErx(erx, TaskStatusCancelled)
+			return c.glideErx(erx)
+		}
+		tasks.setStatus(TaskStatusRunning)
+		args := make([]A, 0, len(tasks))
+		for _, task := range tasks {
+			task.Attempts++
+			args = append(args, task.Arg)
+		}
+		results, erx := run(ctx, args)
+		if constraint.Pass(erx) && len(results) != len(tasks) && c.fallback == nil {
+			erx = c.waLen(&ChunkLenError{Args: len(tasks), Results: len(results)}) // Convert mismatch - must return valid error, not fake zero // 转换数量不一致 - 必须返回有效错误，不能是伪造的零值
+			must.False(constraint.Pass(erx))
+		}
+		if constraint.Pass(erx) && len(results) == len(tasks) {
+			for pos, task := range tasks {
+				task.Res = results[pos]
+				task.Status = TaskStatusSucceeded
+			}
+			return utils.Zero[E]()
+		}
+		if c.fallback != nil {
+			return c.glideErx(c.fallbackRun(ctx, tasks))
+		}
+		tasks.setErx(erx, TaskStatusFailed)
+		return c.glideErx(erx)
+	}
+}
+
+// fallbackRun runs members of failed chunk one by one, returns first member error
+// fallbackRun 逐个执行失败分块的成员，返回第一个成员错误
+func (c *ChunkBatch[A, R, E]) fallbackRun(ctx context.Context, tasks Tasks[A, R, E]) E {
+	var first = utils.Zero[E]()
+	for _, task := range tasks {
+		task.Attempts++
+		res, erx := c.fallback(ctx, task.Arg)
+		if !constraint.Pass(erx) {
+			task.Erx = erx
+			task.Status = TaskStatusFailed
+			if constraint.Pass(first) {
+				first = erx
+			}
+			continue
+		}
+		task.Res = res
+		task.Status = TaskStatusSucceeded
+	}
+	return first
+}
+
+// glideErx returns zero in glide mode, else the given error
+// glideErx 平滑模式下返回零值，否则返回给定错误
+func (c *ChunkBatch[A, R, E]) glideErx(erx E) E {
+	if c.Glide {
+		return utils.Zero[E]() // Glide mode: record error without canceling context // 平滑模式：记录错误但不取消上下文
+	}
+	return erx
+}
+
+// EgoRun schedules each chunk into the provided errgroup
+// EgoRun 将每个分块调度到提供的 errgroup 中
+func (c *ChunkBatch[A, R, E]) EgoRun(ego *erxgroup.Group[E], run func(ctx context.Context, args []A) ([]R, E)) {
+	for chunkIdx := 0; chunkIdx < len(c.Chunks); chunkIdx++ {
+		ego.Go(c.GetRun(chunkIdx, run))
+	}
+}
+
+// SetGlide configures glide mode, see TaskBatch.SetGlide
+// SetGlide 配置平滑模式，参见 TaskBatch.SetGlide
+func (c *ChunkBatch[A, R, E]) SetGlide(glide bool) {
+	c.Glide = glide
+}
+
+// SetWaCtx configures context error conversion function, see TaskBatch.SetWaCtx
+// SetWaCtx 配置上下文错误转换函数，参见 TaskBatch.SetWaCtx
+func (c *ChunkBatch[A, R, E]) SetWaCtx(waCtx func(err error) E) {
+	c.waCtx = waCtx
+}
+
+// SetFallback configures per-argument run invoked on members of failed chunk
+// Each member then records own outcome instead of the chunk error
+//
+// SetFallback 配置在失败分块成员上调用的逐参数执行函数
+// 此时每个成员记录自己的结果而不是分块错误
+func (c *ChunkBatch[A, R, E]) SetFallback(fallback func(ctx context.Context, arg A) (R, E)) {
+	c.fallback = fallback
+}
+
+// SetWaLen configures conversion of *ChunkLenError into chunk error when run returns wrong result count
+// Fallback when set takes precedence, running members one by one instead
+// Either fallback or waLen must be set before GetRun and EgoRun
+//
+// SetWaLen 配置 run 返回的结果数量不正确时将 *ChunkLenError 转换为分块错误的函数
+// 设置了回退函数时回退优先，改为逐个执行成员
+// 在 GetRun 和 EgoRun 之前必须设置回退函数或 waLen
+func (c *ChunkBatch[A, R, E]) SetWaLen(waLen func(err error) E) {
+	c.waLen = waLen
+}
+
+// setStatus sets status on each task
+// setStatus 设置每个任务的状态
+func (tasks Tasks[A, R, E]) setStatus(status TaskStatus) {
+	for _, task := range tasks {
+		task.Status = status
+	}
+}
+
+// setErx sets error and status on each task
+// setErx 设置每个任务的错误和状态
+func (tasks Tasks[A, R, E]) setErx(erx E, status TaskStatus) {
+	for _, task := range tasks {
+		task.Erx = erx
+		task.Status = status
+	}
+}
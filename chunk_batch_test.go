@@ -0,0 +1,155 @@
+package egobatch_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+	"github.com/yyle88/neatjson/neatjsons"
+)
+
+// newWaLen converts result count mismatch into service error
+// newWaLen 将结果数量不一致转换为服务错误
+func newWaLen(err error) *myerrors.Error {
+	return myerrors.ErrorServiceError("wrong-len: %s", err.Error())
+}
+
+func TestChunkBatch_EgoRun(t *testing.T) {
+	args := make([]uint64, 0, 10)
+	for num := uint64(0); num < 10; num++ {
+		args = append(args, num)
+	}
+	chunkBatch := egobatch.NewChunkBatch[uint64, string, *myerrors.Error](args, 4)
+	require.Equal(t, [][]int{{0, 1, 2, 3}, {4, 5, 6, 7}, {8, 9}}, chunkBatch.Chunks)
+	chunkBatch.SetGlide(true)
+	chunkBatch.SetWaLen(newWaLen)
+
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(2)
+	chunkBatch.EgoRun(ego, func(ctx context.Context, args []uint64) ([]string, *myerrors.Error) {
+		results := make([]string, 0, len(args))
+		for _, arg := range args {
+			if arg == 5 {
+				return nil, myerrors.ErrorServiceError("wrong-db")
+			}
+			results = append(results, strconv.FormatUint(arg, 10))
+		}
+		return results, nil
+	})
+	myassert.NoError(t, ego.Wait())
+
+	results := chunkBatch.Tasks.Flatten(func(arg uint64, erk *myerrors.Error) string {
+		return "wa-" + strconv.FormatUint(arg, 10)
+	})
+	t.Log(neatjsons.S(results))
+	require.Equal(t, []string{"0", "1", "2", "3", "wa-4", "wa-5", "wa-6", "wa-7", "8", "9"}, results)
+	require.Len(t, chunkBatch.Tasks.WaTasks(), 4)
+}
+
+func TestChunkBatch_SetFallback(t *testing.T) {
+	args := []uint64{3, 5, 8, 4, 6, 9}
+	chunkBatch := egobatch.NewChunkBatchWeight[uint64, string, *myerrors.Error](args, 10, func(arg uint64) int64 {
+		return int64(arg)
+	})
+	require.Equal(t, [][]int{{0, 1}, {2}, {3, 4}, {5}}, chunkBatch.Chunks)
+	chunkBatch.SetGlide(true)
+	chunkBatch.SetFallback(func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg == 6 {
+			return "", myerrors.ErrorServiceError("wrong-one")
+		}
+		return "one-" + strconv.FormatUint(arg, 10), nil
+	})
+
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	chunkBatch.EgoRun(ego, func(ctx context.Context, args []uint64) ([]string, *myerrors.Error) {
+		if len(args) > 1 && args[0] == 4 {
+			return nil, myerrors.ErrorServiceError("wrong-bulk")
+		}
+		results := make([]string, 0, len(args))
+		for _, arg := range args {
+			results = append(results, strconv.FormatUint(arg, 10))
+		}
+		return results, nil
+	})
+	myassert.NoError(t, ego.Wait())
+
+	results := chunkBatch.Tasks.Flatten(func(arg uint64, erk *myerrors.Error) string {
+		return "wa-" + strconv.FormatUint(arg, 10)
+	})
+	t.Log(neatjsons.S(results))
+	require.Equal(t, []string{"3", "5", "8", "one-4", "wa-6", "9"}, results)
+	require.Equal(t, 2, chunkBatch.Tasks[3].Attempts)
+	require.Equal(t, 1, chunkBatch.Tasks[0].Attempts)
+}
+
+func TestChunkBatch_FailFast(t *testing.T) {
+	args := []uint64{0, 1, 2, 3, 4, 5}
+	chunkBatch := egobatch.NewChunkBatch[uint64, string, *myerrors.Error](args, 2)
+	chunkBatch.SetWaLen(newWaLen)
+	chunkBatch.SetWaCtx(func(err error) *myerrors.Error {
+		return myerrors.ErrorWrongContext("wrong-ctx. error=%v", err)
+	})
+
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(1)
+	chunkBatch.EgoRun(ego, func(ctx context.Context, args []uint64) ([]string, *myerrors.Error) {
+		return nil, myerrors.ErrorServiceError("wrong-bulk")
+	})
+	myassert.Error(t, ego.Wait())
+
+	require.Len(t, chunkBatch.Tasks.Cancelled(), 4)
+	require.True(t, myerrors.IsServiceError(chunkBatch.Tasks[1].Erx))
+}
+
+func TestChunkBatch_SetWaLen(t *testing.T) {
+	// Multi-get leaves out missing keys: chunk {2, 3} misses key 3
+	// 批量查询省略缺失的键：分块 {2, 3} 缺少键 3
+	multiGet := func(ctx context.Context, args []uint64) ([]string, *myerrors.Error) {
+		results := make([]string, 0, len(args))
+		for _, arg := range args {
+			if arg != 3 {
+				results = append(results, strconv.FormatUint(arg, 10))
+			}
+		}
+		return results, nil
+	}
+
+	chunkBatch := egobatch.NewChunkBatch[uint64, string, *myerrors.Error]([]uint64{0, 1, 2, 3}, 2)
+	require.Panics(t, func() { // Neither fallback nor waLen: rejected before scheduling // 既无回退函数也无 waLen：调度前即拒绝
+		_ = chunkBatch.GetRun(1, multiGet)
+	})
+
+	chunkBatch = egobatch.NewChunkBatch[uint64, string, *myerrors.Error]([]uint64{0, 1, 2, 3}, 2)
+	chunkBatch.SetGlide(true)
+	chunkBatch.SetWaLen(newWaLen)
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	chunkBatch.EgoRun(ego, multiGet)
+	myassert.NoError(t, ego.Wait())
+	require.Len(t, chunkBatch.Tasks.OkTasks(), 2)
+	waTasks := chunkBatch.Tasks.WaTasks()
+	require.Len(t, waTasks, 2)
+	require.Contains(t, waTasks[0].Erx.Error(), "chunk run returned 1 results on 2 args")
+
+	// Fallback takes precedence and recovers present members one by one
+	// 回退函数优先，逐个恢复存在的成员
+	chunkBatch = egobatch.NewChunkBatch[uint64, string, *myerrors.Error]([]uint64{0, 1, 2, 3}, 2)
+	chunkBatch.SetGlide(true)
+	chunkBatch.SetFallback(func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg == 3 {
+			return "", myerrors.ErrorServiceError("not-found")
+		}
+		return "one-" + strconv.FormatUint(arg, 10), nil
+	})
+	ego = erxgroup.NewGroup[*myerrors.Error](context.Background())
+	chunkBatch.EgoRun(ego, multiGet)
+	myassert.NoError(t, ego.Wait())
+	results := chunkBatch.Tasks.Flatten(func(arg uint64, erk *myerrors.Error) string {
+		return "wa-" + strconv.FormatUint(arg, 10)
+	})
+	require.Equal(t, []string{"0", "1", "one-2", "wa-3"}, results)
+}
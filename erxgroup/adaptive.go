@@ -0,0 +1,151 @@
+package erxgroup
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yyle88/must"
+	"github.com/yyle88/must/mustnum"
+)
+
+// AdaptiveLimit configures AIMD adaptive concurrency on Group
+// Limit starts at Min and grows by one after each window of healthy completions, a window being limit completions
+// On error or latency spike limit gets multiplied by Backoff, then completions of tasks started under the old limit get ignored
+//
+// AdaptiveLimit 配置 Group 上的 AIMD 自适应并发
+// 限制从 Min 开始，每完成一个窗口的健康任务后加一，窗口大小为当前限制值
+// 出现错误或延迟突增时限制乘以 Backoff，之后忽略在旧限制下启动的任务的完成情况
+type AdaptiveLimit struct {
+	Min        int           // Lower bound and starting limit // 下限及初始限制
+	Max        int           // Upper bound // 上限
+	Backoff    float64       // Multiplicative decrease factor in (0, 1) // 乘性减小因子，取值 (0, 1)
+	MaxLatency time.Duration // Latency above this counts as spike, zero means no absolute bound // 超过此延迟视为突增，零值表示不设绝对上限
+	SpikeRatio float64       // Latency above average healthy latency times ratio counts as spike, non-positive means no relative bound // 超过健康平均延迟乘以该比例视为突增，非正数表示不设相对上限
+}
+
+// NewAdaptiveLimit creates AIMD config with bounds, halving on backoff and spike at double average latency
+// NewAdaptiveLimit 使用上下限创建 AIMD 配置，回退时减半，延迟达到平均值两倍视为突增
+func NewAdaptiveLimit(minLimit int, maxLimit int) *AdaptiveLimit {
+	return &AdaptiveLimit{
+		Min:        minLimit,
+		Max:        maxLimit,
+		Backoff:    0.5,
+		SpikeRatio: 2,
+	}
+}
+
+// adaptiveState tracks AIMD controller progress
+// adaptiveState 记录 AIMD 控制器的进度
+type adaptiveState struct {
+	config AdaptiveLimit // Controller settings // 控制器设置
+	sema   *semaphore    // Semaphore getting limit, updated under mutex so changes land in order // 接收限制的信号量，在锁内更新使调整按顺序生效
+
+	mutex    sync.Mutex    // Guards fields below // 保护下面的字段
+	limit    int           // Current limit // 当前限制
+	healthy  int           // Healthy completions since last change // 上次调整后的健康完成数
+	cooldown int           // Completions to ignore after backoff // 回退后需要忽略的完成数
+	latency  time.Duration // Moving average of healthy latency // 健康延迟的移动平均值
+}
+
+func newAdaptiveState(config *AdaptiveLimit, sema *semaphore) *adaptiveState {
+	mustnum.Positive(config.Min)
+	mustnum.Gte(config.Max, config.Min)
+	must.True(config.Backoff > 0 && config.Backoff < 1)
+	sema.setLimit(int64(config.Min))
+	return &adaptiveState{
+		config: *config,
+		sema:   sema,
+		limit:  config.Min,
+	}
+}
+
+// observe feeds one completion into controller and applies changed limit on semaphore
+// Limit gets applied while holding mutex, so concurrent completions cannot apply stale limits out of order
+//
+// observe 将一次完成情况输入控制器，并将变化后的限制应用到信号量
+// 在持有锁时应用限制，使并发的完成无法乱序应用过时的限制
+func (a *adaptiveState) observe(latency time.Duration, failed bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if limit, changed := a.adjust(latency, failed); changed {
+		a.sema.setLimit(int64(limit))
+	}
+}
+
+// adjust computes limit after one completion (caller holds mutex)
+// Returns new limit and whether it changed
+//
+// adjust 计算一次完成后的限制（调用方持有锁）
+// 返回新的限制以及是否发生变化
+func (a *adaptiveState) adjust(latency time.Duration, failed bool) (int, bool) {
+	if a.limit < 0 {
+		return a.limit, false // Resized to no limit: controller stays idle // 已调整为不限制：控制器保持空闲
+	}
+	if failed || a.spike(latency) {
+		if a.cooldown > 0 {
+			a.cooldown-- // Task started under old limit // 在旧限制下启动的任务
+			return a.limit, false
+		}
+		previous := a.limit
+		a.limit = max(a.config.Min, int(float64(a.limit)*a.config.Backoff))
+		a.cooldown = previous
+		a.healthy = 0
+		return a.limit, a.limit != previous
+	}
+	if a.latency == 0 {
+		a.latency = latency
+	} else {
+		a.latency = (a.latency*4 + latency) / 5 // Moving average weighting latest completion by 1/5 // 最新完成占 1/5 权重的移动平均
+	}
+	if a.cooldown > 0 {
+		a.cooldown--
+		return a.limit, false
+	}
+	a.healthy++
+	if a.healthy >= a.limit && a.limit < a.config.Max {
+		a.limit++
+		a.healthy = 0
+		return a.limit, true
+	}
+	return a.limit, false
+}
+
+// reset moves controller and semaphore onto limit set from outside, starting fresh window
+// reset 将控制器和信号量移动到外部设置的限制，并开始新的窗口
+func (a *adaptiveState) reset(limit int) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.limit = limit
+	a.healthy = 0
+	a.cooldown = 0
+	a.sema.setLimit(int64(limit))
+}
+
+// spike reports whether latency counts as spike (caller holds mutex)
+// spike 报告延迟是否视为突增（调用方持有锁）
+func (a *adaptiveState) spike(latency time.Duration) bool {
+	if a.config.MaxLatency > 0 && latency > a.config.MaxLatency {
+		return true
+	}
+	return a.config.SpikeRatio > 0 && a.latency > 0 && float64(latency) > float64(a.latency)*a.config.SpikeRatio
+}
+
+// adaptiveKey is context key carrying failure flag of running goroutine
+// adaptiveKey 是携带正在运行协程失败标志的上下文键
+type adaptiveKey struct{}
+
+// ReportFailure marks goroutine of ctx as failed on adaptive controller although its run returns zero
+// TaskBatch invokes it on failures kept off the group, as in glide mode, so the limit still backs off
+// Does nothing when ctx does not come from goroutine of a group with adaptive limit
+//
+// ReportFailure 在自适应控制器上将 ctx 对应的协程标记为失败，即使其 run 返回零值
+// TaskBatch 在未返回给 group 的失败（例如平滑模式）上调用它，使限制仍然回退
+// 当 ctx 不是来自启用自适应限制的 group 的协程时不做任何操作
+func ReportFailure(ctx context.Context) {
+	if failed, ok := ctx.Value(adaptiveKey{}).(*atomic.Bool); ok && failed != nil {
+		failed.Store(true)
+	}
+}
@@ -0,0 +1,123 @@
+package erxgroup_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestGroup_SetAdaptiveLimit(t *testing.T) {
+	adaptive := erxgroup.NewAdaptiveLimit(1, 4)
+	adaptive.SpikeRatio = 0 // Sleep jitter must not count as spike // 休眠抖动不能视为突增
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetAdaptiveLimit(adaptive)
+	require.Equal(t, 1, ego.Limit())
+
+	var running, peak atomic.Int64
+	for idx := 0; idx < 40; idx++ {
+		ego.Go(func(ctx context.Context) *myerrors.Error {
+			current := running.Add(1)
+			for {
+				value := peak.Load()
+				if current <= value || peak.CompareAndSwap(value, current) {
+					break
+				}
+			}
+			time.Sleep(2 * time.Millisecond)
+			running.Add(-1)
+			return nil
+		})
+	}
+	myassert.NoError(t, ego.Wait())
+	t.Log(peak.Load())
+	require.Equal(t, 4, ego.Limit())
+	require.Greater(t, peak.Load(), int64(1))
+	require.LessOrEqual(t, peak.Load(), int64(4))
+}
+
+func TestGroup_SetAdaptiveLimit_Backoff(t *testing.T) {
+	adaptive := erxgroup.NewAdaptiveLimit(1, 8)
+	adaptive.SpikeRatio = 0
+	ego := erxgroup.NewCollectGroup[*myerrors.Error](context.Background())
+	ego.SetAdaptiveLimit(adaptive)
+
+	// 1+2+...+7 healthy completions raise limit from 1 to 8
+	// 1+2+...+7 次健康完成将限制从 1 提升到 8
+	for idx := 0; idx < 28; idx++ {
+		ego.Go(func(ctx context.Context) *myerrors.Error {
+			return nil
+		})
+	}
+	myassert.NoError(t, ego.Wait())
+	require.Equal(t, 8, ego.Limit())
+
+	ego.Go(func(ctx context.Context) *myerrors.Error {
+		return myerrors.ErrorServiceError("wrong-db")
+	})
+	require.Len(t, ego.WaitAll(), 1)
+	require.Equal(t, 4, ego.Limit())
+
+	// Next failures fall within cooldown, treated as tasks started under the old limit
+	// 后续失败处于冷却期内，视为在旧限制下启动的任务
+	for idx := 0; idx < 3; idx++ {
+		ego.Go(func(ctx context.Context) *myerrors.Error {
+			return myerrors.ErrorServiceError("wrong-db")
+		})
+		_ = ego.WaitAll()
+	}
+	require.Equal(t, 4, ego.Limit())
+}
+
+func TestGroup_SetAdaptiveLimit_MaxLatency(t *testing.T) {
+	adaptive := erxgroup.NewAdaptiveLimit(2, 8)
+	adaptive.MaxLatency = 100 * time.Millisecond
+	adaptive.SpikeRatio = 0
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetAdaptiveLimit(adaptive)
+
+	for idx := 0; idx < 6; idx++ {
+		ego.Go(func(ctx context.Context) *myerrors.Error {
+			return nil
+		})
+	}
+	myassert.NoError(t, ego.Wait())
+	require.Equal(t, 4, ego.Limit())
+
+	ego.Go(func(ctx context.Context) *myerrors.Error {
+		time.Sleep(150 * time.Millisecond) // Spike above MaxLatency // 超过 MaxLatency 的突增
+		return nil
+	})
+	myassert.NoError(t, ego.Wait())
+	require.Equal(t, 2, ego.Limit())
+}
+
+func TestReportFailure(t *testing.T) {
+	adaptive := erxgroup.NewAdaptiveLimit(1, 8)
+	adaptive.SpikeRatio = 0
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetAdaptiveLimit(adaptive)
+	for idx := 0; idx < 28; idx++ {
+		ego.Go(func(ctx context.Context) *myerrors.Error {
+			return nil
+		})
+	}
+	myassert.NoError(t, ego.Wait())
+	require.Equal(t, 8, ego.Limit())
+
+	// Failure reported without returning error backs off like a returned one
+	// 未返回错误但报告的失败与返回的错误一样触发回退
+	ego.Go(func(ctx context.Context) *myerrors.Error {
+		erxgroup.ReportFailure(ctx)
+		return nil
+	})
+	myassert.NoError(t, ego.Wait())
+	require.Equal(t, 4, ego.Limit())
+
+	erxgroup.ReportFailure(context.Background()) // no group goroutine: does nothing
+}
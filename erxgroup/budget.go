@@ -0,0 +1,112 @@
+package erxgroup
+
+import (
+	"context"
+	"sync"
+
+	"github.com/yyle88/must"
+	"github.com/yyle88/must/mustnum"
+)
+
+// Budget is global concurrency budget shared by nested groups
+// Each goroutine of a group with budget holds one budget slot while it runs, on top of the group own limit
+// Groups created from context of a goroutine holding budget slot inherit the budget
+// While such nested group has goroutines, the parent goroutine releases its slot, taking it back when Wait returns
+// So parents waiting on children never hold every slot, avoiding deadlock
+//
+// Budget 是被嵌套 group 共享的全局并发预算
+// 使用预算的 group 的每个协程在执行期间占用一个预算槽位，叠加在 group 自身限制之上
+// 从占用预算槽位的协程上下文创建的 group 继承该预算
+// 当此类嵌套 group 有协程时，父协程释放自己的槽位，并在 Wait 返回时重新获取
+// 因此等待子任务的父任务不会占用全部槽位，避免死锁
+type Budget struct {
+	sema *semaphore // Budget slots // 预算槽位
+}
+
+// NewBudget creates budget with slots count
+// NewBudget 使用槽位数量创建预算
+func NewBudget(slots int) *Budget {
+	mustnum.Positive(slots)
+	sema := newSemaphore()
+	sema.setLimit(int64(slots))
+	return &Budget{sema: sema}
+}
+
+// Resize changes slots count while goroutines run, see Group.Resize
+// Resize 在协程运行期间修改槽位数量，参见 Group.Resize
+func (b *Budget) Resize(slots int) {
+	b.sema.setLimit(int64(slots))
+}
+
+// Stats returns budget slot usage snapshot, Running counting goroutines holding a slot
+// Stats 返回预算槽位使用情况快照，Running 统计占用槽位的协程数量
+func (b *Budget) Stats() GroupStats {
+	return b.sema.stats()
+}
+
+// hold blocks until one slot is free and returns holder owning it
+// hold 阻塞直到有空闲槽位，返回持有该槽位的持有者
+func (b *Budget) hold() *budgetHolder {
+	must.Done(b.sema.acquire(1)) // Weight one fits any positive budget // 权重为一可容纳于任何正数预算
+	return &budgetHolder{budget: b}
+}
+
+// budgetHolder owns one budget slot on behalf of one running goroutine
+// budgetHolder 代表一个运行中的协程持有一个预算槽位
+type budgetHolder struct {
+	budget    *Budget    // Budget owning the slot // 槽位所属的预算
+	mutex     sync.Mutex // Guards suspended // 保护 suspended
+	suspended int        // Nested groups waiting on children, slot released while positive // 正在等待子任务的嵌套 group 数量，为正数时槽位已释放
+}
+
+// suspend releases slot when first nested group starts goroutines
+// suspend 在第一个嵌套 group 启动协程时释放槽位
+func (h *budgetHolder) suspend() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.suspended++
+	if h.suspended == 1 {
+		h.budget.sema.release(1)
+	}
+}
+
+// resume takes slot back when last nested group finished waiting
+// resume 在最后一个嵌套 group 等待结束时重新获取槽位
+func (h *budgetHolder) resume() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.suspended--
+	if h.suspended == 0 {
+		must.Done(h.budget.sema.acquire(1))
+	}
+}
+
+// release frees slot when goroutine ends, unless a nested group left it suspended
+// release 在协程结束时释放槽位，除非嵌套 group 使其保持挂起
+func (h *budgetHolder) release() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.suspended == 0 {
+		h.budget.sema.release(1)
+	}
+}
+
+// budgetKey is context key carrying budget and the holder of current goroutine
+// budgetKey 是携带预算以及当前协程持有者的上下文键
+type budgetKey struct{}
+
+// budgetValue is context value under budgetKey
+// budgetValue 是 budgetKey 对应的上下文值
+type budgetValue struct {
+	budget *Budget       // Budget inherited by groups created from the context // 从该上下文创建的 group 继承的预算
+	holder *budgetHolder // Slot holder of current goroutine, nil outside budget goroutines // 当前协程的槽位持有者，预算协程之外为 nil
+}
+
+// WithBudget returns context making groups created from it draw from budget
+// Use on the top-level context, nested groups inherit the budget through run context
+//
+// WithBudget 返回使从其创建的 group 使用预算的上下文
+// 在顶层上下文上使用，嵌套 group 通过 run 的上下文继承预算
+func WithBudget(ctx context.Context, budget *Budget) context.Context {
+	return context.WithValue(ctx, budgetKey{}, &budgetValue{budget: budget})
+}
@@ -0,0 +1,117 @@
+package erxgroup_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+// runNested runs 3 levels of groups each with limit 3, returning peak of leaf goroutines running at once
+// runNested 运行 3 层 group，每层限制为 3，返回同时运行的叶子协程峰值
+func runNested(t *testing.T, ctx context.Context) (int64, int64) {
+	var running, peak, leaves atomic.Int64
+	ego := erxgroup.NewGroup[*myerrors.Error](ctx)
+	ego.SetLimit(3)
+	for classIdx := 0; classIdx < 3; classIdx++ {
+		ego.Go(func(ctx context.Context) *myerrors.Error {
+			studentEgo := erxgroup.NewGroup[*myerrors.Error](ctx)
+			studentEgo.SetLimit(3)
+			for studentIdx := 0; studentIdx < 3; studentIdx++ {
+				studentEgo.Go(func(ctx context.Context) *myerrors.Error {
+					subjectEgo := erxgroup.NewGroup[*myerrors.Error](ctx)
+					subjectEgo.SetLimit(3)
+					for subjectIdx := 0; subjectIdx < 3; subjectIdx++ {
+						subjectEgo.Go(func(ctx context.Context) *myerrors.Error {
+							current := running.Add(1)
+							for {
+								value := peak.Load()
+								if current <= value || peak.CompareAndSwap(value, current) {
+									break
+								}
+							}
+							time.Sleep(2 * time.Millisecond)
+							running.Add(-1)
+							leaves.Add(1)
+							return nil
+						})
+					}
+					return subjectEgo.Wait()
+				})
+			}
+			return studentEgo.Wait()
+		})
+	}
+	myassert.NoError(t, ego.Wait())
+	return peak.Load(), leaves.Load()
+}
+
+func TestWithBudget(t *testing.T) {
+	peak, leaves := runNested(t, context.Background())
+	t.Log(peak)
+	require.Equal(t, int64(27), leaves)
+	require.Greater(t, peak, int64(3)) // Without budget concurrency multiplies across levels // 不使用预算时并发数跨层级相乘
+
+	budget := erxgroup.NewBudget(4)
+	peak, leaves = runNested(t, erxgroup.WithBudget(context.Background(), budget))
+	t.Log(peak)
+	require.Equal(t, int64(27), leaves)
+	require.LessOrEqual(t, peak, int64(4))
+	require.Equal(t, erxgroup.GroupStats{Target: 4, Current: 4}, budget.Stats())
+}
+
+func TestWithBudget_SingleSlot(t *testing.T) {
+	// Parents release their slot while waiting on children, so one slot is enough
+	// 父任务等待子任务时释放槽位，因此一个槽位就足够
+	budget := erxgroup.NewBudget(1)
+	type result struct{ peak, leaves int64 }
+	done := make(chan result, 1)
+	go func() {
+		peak, leaves := runNested(t, erxgroup.WithBudget(context.Background(), budget))
+		done <- result{peak: peak, leaves: leaves}
+	}()
+	select {
+	case res := <-done:
+		require.Equal(t, int64(1), res.peak)
+		require.Equal(t, int64(27), res.leaves)
+	case <-time.After(10 * time.Second):
+		t.Fatal("deadlock on single budget slot")
+	}
+}
+
+func TestGroup_SetBudget(t *testing.T) {
+	budget := erxgroup.NewBudget(2)
+	var running, peak atomic.Int64
+	var egos []*erxgroup.Group[*myerrors.Error]
+	for idx := 0; idx < 2; idx++ {
+		// Two independent groups share one budget
+		// 两个独立的 group 共享同一个预算
+		ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+		ego.SetBudget(budget)
+		for taskIdx := 0; taskIdx < 4; taskIdx++ {
+			ego.Go(func(ctx context.Context) *myerrors.Error {
+				current := running.Add(1)
+				for {
+					value := peak.Load()
+					if current <= value || peak.CompareAndSwap(value, current) {
+						break
+					}
+				}
+				time.Sleep(2 * time.Millisecond)
+				running.Add(-1)
+				return nil
+			})
+		}
+		egos = append(egos, ego)
+	}
+	for _, ego := range egos {
+		myassert.NoError(t, ego.Wait())
+	}
+	require.Equal(t, int64(2), peak.Load())
+	require.Equal(t, erxgroup.GroupStats{Target: 2, Current: 2}, budget.Stats())
+}
@@ -8,10 +8,16 @@ package erxgroup
 import (
 	"context"
 	"errors"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/yyle88/egobatch/internal/constraint"
 	"github.com/yyle88/egobatch/internal/utils"
 	"github.com/yyle88/must"
+	"github.com/yyle88/must/mustnum"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -29,6 +35,36 @@ type ErrorType = constraint.ErrorType
 type Group[E ErrorType] struct {
 	ego *errgroup.Group // Underlying errgroup instance // 底层 errgroup 实例
 	ctx context.Context // Shared context with cancellation // 共享的可取消上下文
+
+	cancel context.CancelCauseFunc // Cancels shared context on error before slot release // 在释放槽位之前因错误取消共享上下文
+
+	waPanic func(recovered any, stack []byte) E // Panic conversion function, nil means no recovery // panic 转换函数，nil 表示不恢复
+
+	mutex   sync.Mutex   // Guards spawn index and recorded errors // 保护启动序号和已记录的错误
+	spawned int          // Count of started goroutines, used as spawn index // 已启动协程数量，用作启动序号
+	erxs    []*IdxErx[E] // Every non-zero error with spawn index // 所有非零错误及其启动序号
+	collect bool         // Collect mode: errors do not cancel context // 收集模式：错误不取消上下文
+
+	holds sync.WaitGroup // Schedulers still spawning goroutines, awaited first in Wait // 仍在启动协程的调度者，在 Wait 中首先等待
+
+	limiter *Limiter // Rate limiter awaited before each run, nil means no rate limit // 每次执行前等待的限流器，nil 表示不限流
+
+	sema     *semaphore     // Concurrency slots with runtime-changeable limit // 限制值可在运行时修改的并发槽位
+	adaptive *adaptiveState // AIMD controller, nil means fixed limit // AIMD 控制器，nil 表示固定限制
+
+	budget    *Budget       // Shared budget, nil means no budget // 共享预算，nil 表示不使用预算
+	parent    *budgetHolder // Slot holder of goroutine creating the group, nil means none // 创建 group 的协程的槽位持有者，nil 表示没有
+	suspended atomic.Bool   // Whether parent slot is released while goroutines run // 协程运行期间父槽位是否已释放
+}
+
+// IdxErx pairs non-zero error with spawn index of the goroutine returning it
+// Spawn index counts Go and started TryGo invocations from zero
+//
+// IdxErx 将非零错误与返回它的协程启动序号配对
+// 启动序号从零开始统计 Go 和成功启动的 TryGo 调用
+type IdxErx[E ErrorType] struct {
+	Idx int // Spawn index // 启动序号
+	Erx E   // Error returned by goroutine // 协程返回的错误
 }
 
 // NewGroup creates generic errgroup with custom error type
@@ -37,11 +73,32 @@ type Group[E ErrorType] struct {
 // NewGroup 创建带有自定义错误类型的泛型 errgroup
 // 当第一个错误发生或父上下文取消时，上下文会被取消
 func NewGroup[E ErrorType](ctx context.Context) *Group[E] {
+	ctx, cancel := context.WithCancelCause(ctx)
 	ego, ctx := errgroup.WithContext(ctx)
-	return &Group[E]{
-		ego: ego,
-		ctx: ctx,
+	G := &Group[E]{
+		ego:    ego,
+		ctx:    ctx,
+		cancel: cancel,
+		sema:   newSemaphore(),
+	}
+	if value, ok := ctx.Value(budgetKey{}).(*budgetValue); ok {
+		G.budget = value.budget // Inherit budget from context // 从上下文继承预算
+		G.parent = value.holder
 	}
+	return G
+}
+
+// NewCollectGroup creates generic errgroup collecting every error without canceling context
+// Errors do not cancel siblings, use WaitAll to get complete failure list
+// Context cancels just when parent context cancels or Wait returns
+//
+// NewCollectGroup 创建收集所有错误且不取消上下文的泛型 errgroup
+// 错误不会取消其他协程，使用 WaitAll 获取完整失败列表
+// 上下文仅在父上下文取消或 Wait 返回时取消
+func NewCollectGroup[E ErrorType](ctx context.Context) *Group[E] {
+	G := NewGroup[E](ctx)
+	G.collect = true
+	return G
 }
 
 // Wait blocks awaiting goroutine completion and returns first error
@@ -52,14 +109,55 @@ func NewGroup[E ErrorType](ctx context.Context) *Group[E] {
 // 使用 errors.As 将标准 error 转换回自定义类型 E
 // 当所有协程成功时返回零值
 func (G *Group[E]) Wait() E {
-	if err := G.ego.Wait(); err != nil {
+	G.holds.Wait()
+	err := G.ego.Wait()
+	G.cancel(err)
+	G.resumeParent()
+	if err != nil {
 		var erx E
 		must.True(errors.As(err, &erx))
 		return erx
 	}
+	if G.collect {
+		G.mutex.Lock()
+		defer G.mutex.Unlock()
+		if len(G.erxs) > 0 {
+			return G.erxs[0].Erx // Collect mode: first recorded error // 收集模式：第一个记录的错误
+		}
+	}
 	return utils.Zero[E]()
 }
 
+// WaitAll blocks awaiting goroutine completion and returns every non-zero error
+// Errors sorted by spawn index, empty when execution succeeds
+// In non-collect mode errors after the first one may come from canceled siblings
+//
+// WaitAll 阻塞直到所有协程完成并返回所有非零错误
+// 错误按启动序号排序，全部成功时为空
+// 在非收集模式下第一个错误之后的错误可能来自被取消的协程
+func (G *Group[E]) WaitAll() []*IdxErx[E] {
+	G.holds.Wait()
+	G.cancel(G.ego.Wait())
+	G.resumeParent()
+	G.mutex.Lock()
+	defer G.mutex.Unlock()
+	erxs := append([]*IdxErx[E]{}, G.erxs...)
+	sort.SliceStable(erxs, func(i, j int) bool {
+		return erxs[i].Idx < erxs[j].Idx
+	})
+	return erxs
+}
+
+// Hold keeps Wait and WaitAll blocking until the returned release gets invoked
+// Lets background scheduler keep spawning goroutines while Wait is already invoked
+//
+// Hold 使 Wait 和 WaitAll 阻塞直到返回的释放函数被调用
+// 使后台调度者在 Wait 已被调用时仍可继续启动协程
+func (G *Group[E]) Hold() (release func()) {
+	G.holds.Add(1)
+	return sync.OnceFunc(G.holds.Done)
+}
+
 // Go starts goroutine within the group
 // Converts custom error E to standard error when task fails
 // Task receives shared cancellable context
@@ -68,12 +166,31 @@ func (G *Group[E]) Wait() E {
 // 当任务失败时将自定义错误 E 转换为标准 error
 // 任务接收共享的可取消上下文
 func (G *Group[E]) Go(run func(ctx context.Context) E) {
-	G.ego.Go(func() error {
-		if erx := run(G.ctx); !constraint.Pass(erx) {
-			return erx
-		}
-		return nil
-	})
+	must.Done(G.GoWeighted(1, run)) // Weight one fits any positive capacity // 权重为一可容纳于任何正数容量
+}
+
+// GoWeighted starts goroutine holding weight of the group capacity until run returns
+// Blocks while total weight in flight plus weight exceeds the limit set by SetLimit or Resize
+// Returns *WeightError without starting when weight exceeds capacity, instead of blocking forever
+// Zero weight runs without taking capacity
+//
+// GoWeighted 启动在 run 返回前占用 group 容量中 weight 的协程
+// 当运行中的总权重加上 weight 超过 SetLimit 或 Resize 设置的限制时阻塞
+// 当 weight 超过容量时不启动并返回 *WeightError，而不是永久阻塞
+// 零权重不占用容量
+func (G *Group[E]) GoWeighted(weight int64, run func(ctx context.Context) E) error {
+	mustnum.Gte(weight, 0)
+	G.suspendParent()
+	if err := G.sema.acquire(weight); err != nil {
+		return err
+	}
+	G.mutex.Lock()
+	idx := G.spawned
+	G.spawned++
+	G.mutex.Unlock()
+
+	G.spawn(idx, weight, run)
+	return nil
 }
 
 // TryGo attempts to start goroutine within the group
@@ -84,19 +201,208 @@ func (G *Group[E]) Go(run func(ctx context.Context) E) {
 // 如果达到协程限制则返回 false，如果启动则返回 true
 // 与 Go 方法相同的错误处理
 func (G *Group[E]) TryGo(run func(ctx context.Context) E) bool {
-	return G.ego.TryGo(func() error {
-		if erx := run(G.ctx); !constraint.Pass(erx) {
-			return erx
+	G.mutex.Lock()
+	defer G.mutex.Unlock() // TryGo does not block, holding lock keeps spawn index without gaps // TryGo 不阻塞，持锁保证启动序号连续
+
+	if !G.sema.tryAcquire(1) {
+		return false
+	}
+	G.suspendParent()
+	idx := G.spawned
+	G.spawned++
+	G.spawn(idx, 1, run)
+	return true
+}
+
+// spawn starts goroutine on acquired weight, releasing the weight when run returns
+// spawn 在已获取的权重上启动协程，run 返回时释放权重
+func (G *Group[E]) spawn(idx int, weight int64, run func(ctx context.Context) E) {
+	G.ego.Go(func() error {
+		defer G.sema.release(weight)
+		ctx := G.ctx
+		if G.budget != nil {
+			holder := G.budget.hold() // Taken inside goroutine, so parent gets to Wait and suspend // 在协程内获取，使父任务能进入 Wait 并挂起
+			defer holder.release()
+			ctx = context.WithValue(ctx, budgetKey{}, &budgetValue{budget: G.budget, holder: holder})
 		}
-		return nil
+		G.awaitLimiter()
+		return G.done(idx, G.adaptRun(ctx, run))
 	})
 }
 
-// SetLimit restricts concurrent goroutines count
+// SetBudget attaches shared budget, see Budget
+// Groups created from context of a budget goroutine inherit the budget without invoking it
 // Must be invoked before first Go and TryGo invocation
 //
-// SetLimit 限制并发协程数量
+// SetBudget 挂载共享预算，参见 Budget
+// 从预算协程上下文创建的 group 无需调用即可继承预算
 // 必须在第一次 Go 或 TryGo 调用之前调用
+func (G *Group[E]) SetBudget(budget *Budget) {
+	G.budget = budget
+}
+
+// suspendParent releases slot of parent goroutine on first spawn
+// suspendParent 在第一次启动协程时释放父协程的槽位
+func (G *Group[E]) suspendParent() {
+	if G.parent != nil && G.suspended.CompareAndSwap(false, true) {
+		G.parent.suspend()
+	}
+}
+
+// resumeParent takes slot of parent goroutine back after waiting
+// resumeParent 在等待结束后重新获取父协程的槽位
+func (G *Group[E]) resumeParent() {
+	if G.parent != nil && G.suspended.CompareAndSwap(true, false) {
+		G.parent.resume()
+	}
+}
+
+// done records non-zero error with spawn index and converts it into standard error
+// Returns nil in collect mode so errors do not cancel context
+//
+// done 记录非零错误及启动序号并转换为标准 error
+// 收集模式下返回 nil 使错误不取消上下文
+func (G *Group[E]) done(idx int, erx E) error {
+	if constraint.Pass(erx) {
+		return nil
+	}
+	G.mutex.Lock()
+	G.erxs = append(G.erxs, &IdxErx[E]{Idx: idx, Erx: erx})
+	G.mutex.Unlock()
+	if G.collect {
+		return nil
+	}
+	G.cancel(erx) // Cancel before the slot gets released, so a blocked Go sees done context like errgroup.SetLimit // 在槽位释放之前取消，使阻塞的 Go 像 errgroup.SetLimit 一样看到已结束的上下文
+	return erx
+}
+
+// SetLimit restricts concurrent goroutines count, negative means no limit
+// Unlike errgroup it may be invoked while goroutines run, same as Resize
+//
+// SetLimit 限制并发协程数量，负数表示不限制
+// 与 errgroup 不同，可以在协程运行期间调用，与 Resize 相同
 func (G *Group[E]) SetLimit(n int) {
-	G.ego.SetLimit(n)
+	G.Resize(n)
+}
+
+// Resize changes concurrency limit while goroutines run, negative means no limit
+// Limit caps total weight in flight, Go and TryGo weigh one, see GoWeighted
+// Growing wakes blocked Go invocations at once, shrinking takes effect as running goroutines finish
+// With adaptive limit enabled the controller continues from n, negative n pauses the controller
+//
+// Resize 在协程运行期间修改并发限制，负数表示不限制
+// 限制的是运行中的总权重，Go 和 TryGo 的权重为一，参见 GoWeighted
+// 扩大时立即唤醒阻塞的 Go 调用，缩小时在运行中的协程完成后生效
+// 启用自适应限制时控制器从 n 继续调整，负数 n 使控制器暂停
+func (G *Group[E]) Resize(n int) {
+	if G.adaptive != nil {
+		G.adaptive.reset(n) // Applied under controller lock, racing completions cannot override it with stale limit // 在控制器锁内应用，并发的完成无法用过时的限制覆盖
+		return
+	}
+	G.sema.setLimit(int64(n))
+}
+
+// Limit returns target concurrency limit, negative means no limit
+// With adaptive limit the value moves as the controller adjusts it
+//
+// Limit 返回目标并发限制，负数表示不限制
+// 使用自适应限制时该值随控制器调整而变化
+func (G *Group[E]) Limit() int {
+	return G.sema.stats().Target
+}
+
+// GroupStats reports concurrency limit and slot usage on Group
+// GroupStats 报告 Group 的并发限制和槽位使用情况
+type GroupStats struct {
+	Target  int   // Requested limit, negative means no limit // 请求的限制，负数表示不限制
+	Current int   // Limit in effect, above Target while shrinking waits on running goroutines // 生效中的限制，缩小等待运行中协程完成时高于 Target
+	Running int   // Goroutines holding slot // 占用槽位的协程数量
+	Waiting int   // Go invocations blocked on slot // 阻塞等待槽位的 Go 调用数量
+	Weight  int64 // Total weight in flight, equals Running without weighted runs // 运行中的总权重，不使用加权执行时等于 Running
+}
+
+// Stats returns concurrency limit and slot usage snapshot
+// Stats 返回并发限制和槽位使用情况快照
+func (G *Group[E]) Stats() GroupStats {
+	return G.sema.stats()
+}
+
+// SetAdaptiveLimit enables AIMD adaptive concurrency, replacing fixed limit
+// Starts at adaptive.Min, raises limit while latency and errors stay healthy, backs off on errors or latency spikes
+// Errors count when returned to the group or reported via ReportFailure, TaskBatch reports its task failures
+// Must be invoked before first Go and TryGo invocation
+//
+// SetAdaptiveLimit 启用 AIMD 自适应并发，替代固定限制
+// 从 adaptive.Min 开始，延迟和错误保持健康时提高限制，出现错误或延迟突增时回退
+// 返回给 group 或通过 ReportFailure 报告的错误均计入，TaskBatch 会报告其任务失败
+// 必须在第一次 Go 或 TryGo 调用之前调用
+func (G *Group[E]) SetAdaptiveLimit(adaptive *AdaptiveLimit) {
+	G.adaptive = newAdaptiveState(adaptive, G.sema)
+}
+
+// adaptRun invokes safeRun and feeds latency and outcome into adaptive controller when enabled
+// Outcome counts as failed when run returns error or reports failure via ReportFailure
+//
+// adaptRun 调用 safeRun，启用自适应时将延迟和结果输入控制器
+// 当 run 返回错误或通过 ReportFailure 报告失败时，结果视为失败
+func (G *Group[E]) adaptRun(ctx context.Context, run func(ctx context.Context) E) E {
+	if G.adaptive == nil {
+		if ctx.Value(adaptiveKey{}) != nil {
+			ctx = context.WithValue(ctx, adaptiveKey{}, (*atomic.Bool)(nil)) // Nested group: failures must not reach parent controller // 嵌套 group：失败不能传到父控制器
+		}
+		return G.safeRun(ctx, run)
+	}
+	failed := &atomic.Bool{}
+	ctx = context.WithValue(ctx, adaptiveKey{}, failed)
+	startTime := time.Now()
+	erx := G.safeRun(ctx, run)
+	G.adaptive.observe(time.Since(startTime), !constraint.Pass(erx) || failed.Load())
+	return erx
+}
+
+// SetWaPanic configures panic conversion function
+// Converts recovered panic value with stack into custom error type E
+// Must be invoked before first Go and TryGo invocation
+//
+// SetWaPanic 配置 panic 转换函数
+// 将恢复的 panic 值及堆栈转换为自定义错误类型 E
+// 必须在第一次 Go 或 TryGo 调用之前调用
+func (G *Group[E]) SetWaPanic(waPanic func(recovered any, stack []byte) E) {
+	G.waPanic = waPanic
+}
+
+// safeRun invokes run with goroutine context and converts panic into error E when waPanic is set
+// Without waPanic the panic propagates and crashes the process as errgroup does
+//
+// safeRun 使用协程上下文调用 run，当设置 waPanic 时将 panic 转换为错误 E
+// 未设置 waPanic 时 panic 照常传播，与 errgroup 行为一致
+func (G *Group[E]) safeRun(ctx context.Context, run func(ctx context.Context) E) (erx E) {
+	if G.waPanic != nil {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				erx = G.waPanic(recovered, debug.Stack()) // Convert panic - must return valid error, not fake zero // 转换 panic - 必须返回有效错误，不能是伪造的零值
+				must.False(constraint.Pass(erx))
+			}
+		}()
+	}
+	return run(ctx)
+}
+
+// SetRateLimit attaches token-bucket rate limiter awaited inside each goroutine before run
+// When context finishes during the wait, run still gets invoked and observes the done context
+// Must be invoked before first Go and TryGo invocation
+//
+// SetRateLimit 挂载令牌桶限流器，在每个协程内执行 run 之前等待
+// 当等待期间上下文结束时，run 仍会被调用并观察到已结束的上下文
+// 必须在第一次 Go 或 TryGo 调用之前调用
+func (G *Group[E]) SetRateLimit(limiter *Limiter) {
+	G.limiter = limiter
+}
+
+// awaitLimiter waits on rate limiter when attached, ignoring context error left to run
+// awaitLimiter 在挂载限流器时等待，上下文错误交由 run 处理
+func (G *Group[E]) awaitLimiter() {
+	if G.limiter != nil {
+		_, _ = G.limiter.Wait(G.ctx)
+	}
 }
@@ -3,6 +3,7 @@ package erxgroup_test
 import (
 	"context"
 	"math/rand/v2"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -90,3 +91,189 @@ func stepRun(ctx context.Context, idx int) *myerrors.Error {
 	zaplog.LOG.Info("task ok", zap.Int("num", idx))
 	return nil
 }
+
+func TestGroup_Hold(t *testing.T) {
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	release := ego.Hold()
+	var runs atomic.Int32
+	go func() {
+		defer release()
+		time.Sleep(20 * time.Millisecond) // Spawn after Wait is already invoked // 在 Wait 已被调用之后启动
+		ego.Go(func(ctx context.Context) *myerrors.Error {
+			runs.Add(1)
+			return nil
+		})
+	}()
+	myassert.NoError(t, ego.Wait())
+	require.Equal(t, int32(1), runs.Load())
+}
+
+func TestGroup_SetWaPanic(t *testing.T) {
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetWaPanic(func(recovered any, stack []byte) *myerrors.Error {
+		require.NotEmpty(t, stack)
+		return myerrors.New("PANIC_ERROR", "panic=%v", recovered)
+	})
+
+	ego.Go(func(ctx context.Context) *myerrors.Error {
+		return nil
+	})
+	require.True(t, ego.TryGo(func(ctx context.Context) *myerrors.Error {
+		panic("bad record")
+	}))
+
+	erx := ego.Wait()
+	myassert.Error(t, erx)
+	require.Equal(t, "PANIC_ERROR", erx.Code())
+	t.Log(erx)
+}
+
+func TestNewCollectGroup(t *testing.T) {
+	ego := erxgroup.NewCollectGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(3)
+
+	for idx := 0; idx < 10; idx++ {
+		num := idx
+		ego.Go(func(ctx context.Context) *myerrors.Error {
+			if ctx.Err() != nil {
+				return myerrors.ErrorWrongContext("error=%v", ctx.Err())
+			}
+			if num%3 == 0 {
+				return myerrors.ErrorServiceError("task wa %d", num)
+			}
+			return nil
+		})
+	}
+
+	erxs := ego.WaitAll()
+	require.Len(t, erxs, 4)
+	for pos, one := range erxs {
+		t.Log(one.Idx, one.Erx)
+		require.Equal(t, pos*3, one.Idx)
+		require.True(t, myerrors.IsServiceError(one.Erx)) // errors do not cancel siblings
+	}
+	myassert.Error(t, ego.Wait())
+}
+
+func TestGroup_WaitAll(t *testing.T) {
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+
+	require.True(t, ego.TryGo(func(ctx context.Context) *myerrors.Error {
+		return nil
+	}))
+	require.True(t, ego.TryGo(func(ctx context.Context) *myerrors.Error {
+		return myerrors.ErrorServiceError("task wa")
+	}))
+
+	erxs := ego.WaitAll()
+	require.Len(t, erxs, 1)
+	require.Equal(t, 1, erxs[0].Idx)
+	require.True(t, myerrors.IsServiceError(ego.Wait()))
+}
+
+func TestGroup_Resize(t *testing.T) {
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(4)
+
+	release := make(chan struct{})
+	for idx := 0; idx < 4; idx++ {
+		ego.Go(func(ctx context.Context) *myerrors.Error {
+			<-release
+			return nil
+		})
+	}
+	require.Equal(t, erxgroup.GroupStats{Target: 4, Current: 4, Running: 4, Waiting: 0, Weight: 4}, ego.Stats())
+
+	// Shrink while 4 goroutines run: target drops at once, current follows as they finish
+	// 4 个协程运行时缩小：目标立即下降，当前值随协程完成而下降
+	ego.Resize(1)
+	require.Equal(t, erxgroup.GroupStats{Target: 1, Current: 4, Running: 4, Waiting: 0, Weight: 4}, ego.Stats())
+
+	spawned := make(chan struct{})
+	var maxRunning atomic.Int64
+	go func() {
+		defer close(spawned)
+		for idx := 0; idx < 3; idx++ {
+			ego.Go(func(ctx context.Context) *myerrors.Error {
+				maxRunning.Store(max(maxRunning.Load(), int64(ego.Stats().Running)))
+				return nil
+			})
+		}
+	}()
+	require.Eventually(t, func() bool {
+		return ego.Stats().Waiting == 1
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	<-spawned
+	myassert.NoError(t, ego.Wait())
+	require.Equal(t, int64(1), maxRunning.Load())
+	require.Equal(t, erxgroup.GroupStats{Target: 1, Current: 1, Running: 0, Waiting: 0}, ego.Stats())
+
+	ego.Resize(-1)
+	require.Equal(t, -1, ego.Stats().Current)
+}
+
+func TestGroup_GoWeighted(t *testing.T) {
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(10)
+
+	var inFlight, peak atomic.Int64
+	for _, weight := range []int64{6, 6, 3, 3, 1, 8} {
+		require.NoError(t, ego.GoWeighted(weight, func(ctx context.Context) *myerrors.Error {
+			current := inFlight.Add(weight)
+			for {
+				value := peak.Load()
+				if current <= value || peak.CompareAndSwap(value, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			inFlight.Add(-weight)
+			return nil
+		}))
+	}
+
+	// Heavier than capacity: typed error at once instead of blocking forever
+	// 超过容量：立即返回带类型的错误而不是永久阻塞
+	err := ego.GoWeighted(11, func(ctx context.Context) *myerrors.Error {
+		return nil
+	})
+	var weightError *erxgroup.WeightError
+	require.ErrorAs(t, err, &weightError)
+	require.Equal(t, &erxgroup.WeightError{Weight: 11, Capacity: 10}, weightError)
+
+	myassert.NoError(t, ego.Wait())
+	t.Log(peak.Load())
+	require.LessOrEqual(t, peak.Load(), int64(10))
+	require.Equal(t, int64(0), ego.Stats().Weight)
+}
+
+func TestGroup_GoWeighted_ShrinkWhileWaiting(t *testing.T) {
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(10)
+
+	release := make(chan struct{})
+	require.NoError(t, ego.GoWeighted(10, func(ctx context.Context) *myerrors.Error {
+		<-release
+		return nil
+	}))
+
+	result := make(chan error, 1)
+	go func() {
+		result <- ego.GoWeighted(8, func(ctx context.Context) *myerrors.Error {
+			return nil
+		})
+	}()
+	require.Eventually(t, func() bool {
+		return ego.Stats().Waiting == 1
+	}, time.Second, time.Millisecond)
+
+	ego.Resize(5) // Waiting weight no longer fits // 等待中的权重不再可容纳
+	var weightError *erxgroup.WeightError
+	require.ErrorAs(t, <-result, &weightError)
+	require.Equal(t, int64(5), weightError.Capacity)
+
+	close(release)
+	myassert.NoError(t, ego.Wait())
+}
@@ -0,0 +1,123 @@
+package erxgroup
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yyle88/must/mustnum"
+)
+
+// Limiter is token-bucket rate limiter capping throughput rather than concurrency
+// Tokens refill at rate per second up to burst, each wait takes one token
+// Can be shared by multiple Group and TaskBatch instances enforcing one QPS quota
+//
+// Limiter 是令牌桶限流器，限制吞吐量而不是并发数
+// 令牌按每秒 rate 个补充，最多 burst 个，每次等待消耗一个令牌
+// 可被多个 Group 和 TaskBatch 实例共享以执行同一个 QPS 配额
+type Limiter struct {
+	rate  float64 // Tokens added per second // 每秒补充的令牌数
+	burst int     // Bucket capacity // 令牌桶容量
+
+	mutex  sync.Mutex   // Guards fields below // 保护下面的字段
+	tokens float64      // Available tokens, negative when reserved ahead // 可用令牌数，预约时可为负数
+	last   time.Time    // Last refill time // 上次补充时间
+	stats  LimiterStats // Wait statistics // 等待统计
+}
+
+// LimiterStats reports limiter wait statistics
+// LimiterStats 报告限流器等待统计
+type LimiterStats struct {
+	Waits    int           // Wait invocations // 等待调用次数
+	Delayed  int           // Waits that had to sleep // 需要休眠的等待次数
+	WaitTime time.Duration // Total time spent waiting // 等待总时长
+	Canceled int           // Waits stopped by context // 被上下文中止的等待次数
+}
+
+// NewLimiter creates limiter with rate per second and burst capacity
+// Bucket starts full so first burst waits pass without delay
+//
+// NewLimiter 使用每秒速率和突发容量创建限流器
+// 令牌桶初始为满，因此首批突发等待无需延迟
+func NewLimiter(rate float64, burst int) *Limiter {
+	mustnum.Positive(rate)
+	mustnum.Positive(burst)
+	return &Limiter{
+		rate:   rate,
+		burst:  burst,
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Wait blocks until one token is available or context finishes
+// Returns waited duration, and context error when context finishes first (token gets returned)
+//
+// Wait 阻塞直到获得一个令牌或上下文结束
+// 返回等待时长，上下文先结束时返回上下文错误（令牌会被归还）
+func (l *Limiter) Wait(ctx context.Context) (time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	delay := l.reserve()
+	if delay <= 0 {
+		return 0, nil
+	}
+	start := time.Now()
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		waited := time.Since(start)
+		l.cancel(waited)
+		return waited, ctx.Err()
+	case <-timer.C:
+		waited := time.Since(start)
+		l.finish(waited)
+		return waited, nil
+	}
+}
+
+// reserve takes one token and returns delay until the token becomes available
+// reserve 预约一个令牌并返回令牌可用前需要的延迟
+func (l *Limiter) reserve() time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.tokens = min(float64(l.burst), l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	l.stats.Waits++
+	if l.tokens >= 0 {
+		return 0
+	}
+	l.stats.Delayed++
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// finish records completed delayed wait
+// finish 记录完成的延迟等待
+func (l *Limiter) finish(waited time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.stats.WaitTime += waited
+}
+
+// cancel returns reserved token and records canceled wait
+// cancel 归还预约的令牌并记录被取消的等待
+func (l *Limiter) cancel(waited time.Duration) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.tokens++
+	l.stats.WaitTime += waited
+	l.stats.Canceled++
+}
+
+// Stats returns limiter wait statistics
+// Stats 返回限流器等待统计
+func (l *Limiter) Stats() LimiterStats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.stats
+}
@@ -0,0 +1,60 @@
+package erxgroup_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestLimiter_Wait(t *testing.T) {
+	limiter := erxgroup.NewLimiter(100, 2)
+	ctx := context.Background()
+
+	startTime := time.Now()
+	for idx := 0; idx < 6; idx++ {
+		_, err := limiter.Wait(ctx)
+		require.NoError(t, err)
+	}
+	elapsed := time.Since(startTime)
+	t.Log(elapsed)
+	require.GreaterOrEqual(t, elapsed, time.Millisecond*35) // 2 burst tokens, then 4 tokens at 10ms each
+
+	stats := limiter.Stats()
+	require.Equal(t, 6, stats.Waits)
+	require.Equal(t, 4, stats.Delayed)
+	require.Greater(t, stats.WaitTime, time.Duration(0))
+}
+
+func TestLimiter_Wait_ContextDone(t *testing.T) {
+	limiter := erxgroup.NewLimiter(1, 1)
+	_, err := limiter.Wait(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Millisecond*20)
+	defer cancelFunc()
+	waited, err := limiter.Wait(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Greater(t, waited, time.Duration(0))
+	require.Equal(t, 1, limiter.Stats().Canceled)
+}
+
+func TestGroup_SetRateLimit(t *testing.T) {
+	limiter := erxgroup.NewLimiter(200, 1)
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetRateLimit(limiter)
+
+	startTime := time.Now()
+	for idx := 0; idx < 5; idx++ {
+		ego.Go(func(ctx context.Context) *myerrors.Error {
+			return nil
+		})
+	}
+	myassert.NoError(t, ego.Wait())
+	require.GreaterOrEqual(t, time.Since(startTime), time.Millisecond*15) // 4 delayed tokens at 5ms each
+	require.Equal(t, 5, limiter.Stats().Waits)
+}
@@ -0,0 +1,118 @@
+package erxgroup
+
+import (
+	"fmt"
+	"sync"
+)
+
+// semaphore caps total weight of running goroutines with limit that can change at runtime
+// Plain goroutines weigh one, so the limit counts goroutines unless weighted runs are used
+// Unlike errgroup.SetLimit the limit may change while goroutines run
+// Shrinking takes effect as running goroutines release, none gets interrupted
+//
+// semaphore 限制运行中协程的总权重，限制值可在运行时修改
+// 普通协程权重为一，因此不使用加权执行时限制即为协程数量
+// 与 errgroup.SetLimit 不同，限制值可以在协程运行期间修改
+// 缩小限制在运行中的协程释放时生效，不会中断任何协程
+type semaphore struct {
+	mutex   sync.Mutex // Guards fields below // 保护下面的字段
+	cond    *sync.Cond // Signals released weight and changed limit // 通知权重释放和限制变化
+	limit   int64      // Weight capacity, negative means no limit, zero pauses // 权重容量，负数表示不限制，零表示暂停
+	used    int64      // Weight taken // 已占用的权重
+	running int        // Goroutines holding weight // 持有权重的协程数量
+	waiting int        // Goroutines blocked in acquire // 阻塞在 acquire 中的协程数量
+}
+
+func newSemaphore() *semaphore {
+	sema := &semaphore{limit: -1}
+	sema.cond = sync.NewCond(&sema.mutex)
+	return sema
+}
+
+// WeightError reports weighted run heavier than group capacity, which would otherwise block forever
+// WeightError 报告加权执行的权重超过 group 容量，否则会永久阻塞
+type WeightError struct {
+	Weight   int64 // Requested weight // 请求的权重
+	Capacity int64 // Group capacity at check time // 检查时 group 的容量
+}
+
+func (e *WeightError) Error() string {
+	return fmt.Sprintf("erxgroup: weight %d exceeds capacity %d", e.Weight, e.Capacity)
+}
+
+// setLimit changes weight capacity and wakes waiters
+// setLimit 修改权重容量并唤醒等待者
+func (s *semaphore) setLimit(limit int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.limit = limit
+	s.cond.Broadcast()
+}
+
+// acquire blocks until weight fits and takes it
+// Returns WeightError when weight exceeds positive capacity, also when capacity shrinks below it during the wait
+//
+// acquire 阻塞直到权重可容纳并占用它
+// 当权重超过正数容量时返回 WeightError，等待期间容量缩小到权重以下时同样返回
+func (s *semaphore) acquire(weight int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for !s.fits(weight) {
+		if s.limit > 0 && weight > s.limit {
+			return &WeightError{Weight: weight, Capacity: s.limit}
+		}
+		s.waiting++
+		s.cond.Wait()
+		s.waiting--
+	}
+	s.used += weight
+	s.running++
+	return nil
+}
+
+// tryAcquire takes weight when it fits without blocking
+// tryAcquire 在权重可容纳时占用它，不阻塞
+func (s *semaphore) tryAcquire(weight int64) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.fits(weight) {
+		return false
+	}
+	s.used += weight
+	s.running++
+	return true
+}
+
+// release frees weight and wakes waiters
+// release 释放权重并唤醒等待者
+func (s *semaphore) release(weight int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.used -= weight
+	s.running--
+	s.cond.Broadcast()
+}
+
+// fits reports whether weight fits in free capacity (caller holds mutex)
+// fits 报告剩余容量是否可容纳权重（调用方持有锁）
+func (s *semaphore) fits(weight int64) bool {
+	return s.limit < 0 || s.used+weight <= s.limit
+}
+
+// stats returns weight usage snapshot
+// stats 返回权重使用情况快照
+func (s *semaphore) stats() GroupStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	current := s.limit
+	if s.limit >= 0 && s.used > s.limit {
+		current = s.used // Shrinking: excess capacity gets dropped as running goroutines release // 缩小中：多余容量在运行中的协程释放时移除
+	}
+	return GroupStats{
+		Target:  int(s.limit),
+		Current: int(current),
+		Running: s.running,
+		Waiting: s.waiting,
+		Weight:  s.used,
+	}
+}
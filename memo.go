@@ -0,0 +1,252 @@
+package egobatch
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/yyle88/egobatch/internal/constraint"
+	"github.com/yyle88/egobatch/internal/utils"
+	"github.com/yyle88/must"
+)
+
+// MemoStats reports memo cache statistics
+// MemoStats 报告缓存统计信息
+type MemoStats struct {
+	Hits      int // Lookups answered by cached entry // 命中缓存的查询次数
+	Misses    int // Lookups invoking run // 调用 run 的查询次数
+	Coalesced int // Lookups sharing in-flight run of concurrent miss // 共享并发未命中中进行中调用的查询次数
+	Evictions int // Entries evicted by size bound // 因容量上限被淘汰的条目数
+}
+
+// Memo caches run outcomes by key across TaskBatch executions
+// Entries expire after TTL, least recently used entry gets evicted when size bound is reached
+// Concurrent misses on same key get coalesced into one run invocation
+// Failures get cached just when failure TTL is set
+//
+// Memo 按键缓存 run 的结果，可跨多个 TaskBatch 执行复用
+// 条目在 TTL 后过期，达到容量上限时淘汰最近最少使用的条目
+// 同一个键的并发未命中会合并为一次 run 调用
+// 仅当设置失败 TTL 时才缓存失败结果
+type Memo[K comparable, R any, E ErrorType] struct {
+	okTTL   time.Duration // Success entry lifetime, non-positive means no expiry // 成功条目存活时间，非正数表示不过期
+	waTTL   time.Duration // Failure entry lifetime, non-positive means failures not cached // 失败条目存活时间，非正数表示不缓存失败
+	maxSize int           // Entry count bound, non-positive means no bound // 条目数量上限，非正数表示不限制
+
+	waCtx func(err error) E // Context error conversion on waiters, nil means waiting until leader ends // 等待者的上下文错误转换函数，nil 表示一直等到领头调用结束
+
+	mutex    sync.Mutex            // Guards fields below // 保护下面的字段
+	entries  map[K]*list.Element   // Cached entries by key // 按键保存的缓存条目
+	lru      *list.List            // Entries from most to least recently used // 从最近到最久使用排列的条目
+	inflight map[K]*memoCall[R, E] // Running misses by key // 按键保存的进行中未命中调用
+	stats    MemoStats             // Cache statistics // 缓存统计
+}
+
+// memoEntry holds cached outcome with expiry time
+// memoEntry 保存缓存的结果及过期时间
+type memoEntry[K comparable, R any, E ErrorType] struct {
+	key    K         // Entry key // 条目键
+	res    R         // Cached result // 缓存的结果
+	erx    E         // Cached error // 缓存的错误
+	expire time.Time // Expiry time, zero means no expiry // 过期时间，零值表示不过期
+}
+
+// memoCall holds in-flight run shared by concurrent misses
+// memoCall 保存被并发未命中共享的进行中调用
+type memoCall[R any, E ErrorType] struct {
+	done chan struct{} // Closed when outcome is ready // 结果就绪时关闭
+	res  R             // Run result // 调用结果
+	erx  E             // Run error // 调用错误
+
+	panicked any  // Panic value of run, nil when run returned // run 的 panic 值，正常返回时为 nil
+	private  bool // Outcome caused by leader's own context ending, not shared // 结果由领头调用自身上下文结束导致，不共享
+}
+
+// NewMemo creates memo cache with success TTL and size bound
+// NewMemo 使用成功 TTL 和容量上限创建缓存
+func NewMemo[K comparable, R any, E ErrorType](okTTL time.Duration, maxSize int) *Memo[K, R, E] {
+	return &Memo[K, R, E]{
+		okTTL:    okTTL,
+		maxSize:  maxSize,
+		entries:  map[K]*list.Element{},
+		lru:      list.New(),
+		inflight: map[K]*memoCall[R, E]{},
+	}
+}
+
+// SetWaTTL configures failure caching, non-positive means failures not cached
+// SetWaTTL 配置失败缓存，非正数表示不缓存失败
+func (m *Memo[K, R, E]) SetWaTTL(waTTL time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.waTTL = waTTL
+}
+
+// SetWaCtx configures context error conversion on coalesced waiters
+// Waiting on in-flight run of another caller then stops when ctx ends, returning converted error without invoking run
+// Without it waiters wait until the leader ends, so a backend never gets invoked twice on one miss
+//
+// SetWaCtx 配置合并等待者的上下文错误转换函数
+// 此时等待其他调用方进行中的 run 时，ctx 结束即停止等待，返回转换后的错误而不调用 run
+// 未设置时等待者一直等到领头调用结束，因此一次未命中不会两次调用后端
+func (m *Memo[K, R, E]) SetWaCtx(waCtx func(err error) E) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.waCtx = waCtx
+}
+
+// Get returns cached outcome on key, invoking run on miss
+// Waiting on in-flight run of another caller stops when ctx ends just when waCtx is set, see SetWaCtx
+// Failure of a run whose own context ended stays with that caller, neither shared nor cached
+//
+// Get 返回键对应的缓存结果，未命中时调用 run
+// 仅当设置 waCtx 时，等待其他调用方进行中的 run 才会在 ctx 结束时停止，参见 SetWaCtx
+// 自身上下文结束导致的 run 失败只属于该调用方，既不共享也不缓存
+func (m *Memo[K, R, E]) Get(ctx context.Context, key K, run func(ctx context.Context, key K) (R, E)) (R, E) {
+	for {
+		m.mutex.Lock()
+		if element, ok := m.entries[key]; ok {
+			entry := element.Value.(*memoEntry[K, R, E])
+			if entry.expire.IsZero() || time.Now().Before(entry.expire) {
+				m.lru.MoveToFront(element)
+				m.stats.Hits++
+				m.mutex.Unlock()
+				return entry.res, entry.erx
+			}
+			m.lru.Remove(element) // Expired entry // 已过期条目
+			delete(m.entries, key)
+		}
+		call, ok := m.inflight[key]
+		if !ok {
+			call = &memoCall[R, E]{done: make(chan struct{})}
+			m.inflight[key] = call
+			m.stats.Misses++
+			m.mutex.Unlock()
+			return m.lead(ctx, key, call, run)
+		}
+		m.stats.Coalesced++
+		waCtx := m.waCtx
+		m.mutex.Unlock()
+		if waCtx == nil {
+			<-call.done // No converter: wait for the leader outcome // 无转换函数：等待领头调用的结果
+		} else {
+			select {
+			case <-call.done:
+			case <-ctx.Done():
+				erx := waCtx(ctx.Err()) // Convert own context error - must return valid error, not fake zero // 转换自身上下文错误 - 必须返回有效错误，不能是伪造的零值
+				must.False(constraint.Pass(erx))
+				return utils.Zero[R](), erx
+			}
+		}
+		if call.panicked != nil {
+			panic(call.panicked) // Leader panicked, waiters panic alike so no zero outcome passes as success // 领头调用 panic，等待者同样 panic，避免零值结果被当作成功
+		}
+		if !call.private {
+			return call.res, call.erx
+		}
+		// Leader failed on own context ending, look up again // 领头调用因自身上下文结束而失败，重新查询
+	}
+}
+
+// lead invokes run on behalf of coalesced misses and publishes outcome
+// Panic of run gets recorded on call and raised again, nothing gets cached
+//
+// lead 代表合并的未命中调用 run 并发布结果
+// run 的 panic 会记录在 call 上并再次抛出，不缓存任何结果
+func (m *Memo[K, R, E]) lead(ctx context.Context, key K, call *memoCall[R, E], run func(ctx context.Context, key K) (R, E)) (R, E) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			call.panicked = recovered
+			m.finish(key, call)
+			panic(recovered)
+		}
+	}()
+	call.res, call.erx = run(ctx, key)
+	call.private = !constraint.Pass(call.erx) && ctx.Err() != nil
+	if !call.private {
+		m.store(key, call.res, call.erx)
+	}
+	m.finish(key, call)
+	return call.res, call.erx
+}
+
+// finish removes in-flight call and wakes waiters
+// finish 移除进行中的调用并唤醒等待者
+func (m *Memo[K, R, E]) finish(key K, call *memoCall[R, E]) {
+	m.mutex.Lock()
+	delete(m.inflight, key)
+	m.mutex.Unlock()
+	close(call.done)
+}
+
+// store caches outcome honoring TTL settings and size bound
+// store 按 TTL 设置和容量上限缓存结果
+func (m *Memo[K, R, E]) store(key K, res R, erx E) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ttl := m.okTTL
+	if !constraint.Pass(erx) {
+		if m.waTTL <= 0 {
+			return
+		}
+		ttl = m.waTTL
+	}
+	entry := &memoEntry[K, R, E]{key: key, res: res, erx: erx}
+	if ttl > 0 {
+		entry.expire = time.Now().Add(ttl)
+	}
+	if element, ok := m.entries[key]; ok {
+		element.Value = entry
+		m.lru.MoveToFront(element)
+		return
+	}
+	m.entries[key] = m.lru.PushFront(entry)
+	for m.maxSize > 0 && m.lru.Len() > m.maxSize {
+		oldest := m.lru.Back()
+		m.lru.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoEntry[K, R, E]).key)
+		m.stats.Evictions++
+	}
+}
+
+// Forget removes cached entry on key
+// Forget 删除键对应的缓存条目
+func (m *Memo[K, R, E]) Forget(key K) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if element, ok := m.entries[key]; ok {
+		m.lru.Remove(element)
+		delete(m.entries, key)
+	}
+}
+
+// Len returns cached entry count, expired entries included until next lookup
+// Len 返回缓存条目数量，过期条目在下次查询前仍被计入
+func (m *Memo[K, R, E]) Len() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.lru.Len()
+}
+
+// Stats returns memo cache statistics
+// Stats 返回缓存统计信息
+func (m *Memo[K, R, E]) Stats() MemoStats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.stats
+}
+
+// MemoRun wraps run with memo cache, keyed by key extractor on argument
+// Returned function fits TaskBatch.EgoRun and can be shared across batches
+//
+// MemoRun 使用缓存包装 run，通过参数上的键提取函数获取键
+// 返回的函数适用于 TaskBatch.EgoRun，可在多个批量之间共享
+func MemoRun[A any, K comparable, R any, E ErrorType](memo *Memo[K, R, E], key func(arg A) K, run func(ctx context.Context, arg A) (R, E)) func(ctx context.Context, arg A) (R, E) {
+	return func(ctx context.Context, arg A) (R, E) {
+		return memo.Get(ctx, key(arg), func(ctx context.Context, key K) (R, E) {
+			return run(ctx, arg)
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package egobatch_test
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestMemoRun(t *testing.T) {
+	memo := egobatch.NewMemo[int, string, *myerrors.Error](time.Minute, 100)
+
+	var calls atomic.Int64
+	run := egobatch.MemoRun(memo, func(arg int) int {
+		return arg % 5
+	}, func(ctx context.Context, arg int) (string, *myerrors.Error) {
+		calls.Add(1)
+		time.Sleep(time.Millisecond * 10)
+		if arg%5 == 4 {
+			return "", myerrors.ErrorServiceError("wrong-db")
+		}
+		return "score-" + strconv.Itoa(arg%5), nil
+	})
+
+	args := make([]int, 0, 20)
+	for num := 0; num < 20; num++ {
+		args = append(args, num)
+	}
+	for round := 0; round < 2; round++ { // identical lookups repeat across batches
+		tasks, erx := egobatch.NewRunner(run).WithGlide(true).WithLimit(5).Run(context.Background(), args)
+		myassert.NoError(t, erx)
+		require.Len(t, tasks.OkTasks(), 16)
+		require.Len(t, tasks.WaTasks(), 4)
+	}
+
+	stats := memo.Stats()
+	t.Log(stats)
+	require.Equal(t, 4, memo.Len()) // failures not cached
+	require.GreaterOrEqual(t, calls.Load(), int64(4+2))
+	require.LessOrEqual(t, calls.Load(), int64(4+2*4)) // ok keys run once, failed key runs on each miss
+	require.Equal(t, stats.Misses, int(calls.Load()))
+	require.Equal(t, 40, stats.Hits+stats.Misses+stats.Coalesced)
+}
+
+func TestMemo_Get(t *testing.T) {
+	memo := egobatch.NewMemo[string, int, *myerrors.Error](time.Millisecond*50, 2)
+	memo.SetWaTTL(time.Minute)
+
+	var calls int
+	run := func(ctx context.Context, key string) (int, *myerrors.Error) {
+		calls++
+		if key == "wa" {
+			return 0, myerrors.ErrorServiceError("wrong-db")
+		}
+		return len(key), nil
+	}
+	ctx := context.Background()
+
+	res, erx := memo.Get(ctx, "a", run)
+	myassert.NoError(t, erx)
+	require.Equal(t, 1, res)
+	_, erx = memo.Get(ctx, "wa", run)
+	myassert.Error(t, erx)
+	_, erx = memo.Get(ctx, "wa", run) // failure cached
+	myassert.Error(t, erx)
+	require.Equal(t, 2, calls)
+
+	_, _ = memo.Get(ctx, "bb", run) // evicts least recently used "a"
+	require.Equal(t, 2, memo.Len())
+	require.Equal(t, 1, memo.Stats().Evictions)
+	_, _ = memo.Get(ctx, "a", run)
+	require.Equal(t, 4, calls)
+
+	time.Sleep(time.Millisecond * 60) // success entry expired
+	_, _ = memo.Get(ctx, "a", run)
+	require.Equal(t, 5, calls)
+
+	memo.Forget("a")
+	_, _ = memo.Get(ctx, "a", run)
+	require.Equal(t, 6, calls)
+}
+
+func TestMemoRun_Panic(t *testing.T) {
+	memo := egobatch.NewMemo[int, string, *myerrors.Error](time.Minute, 100)
+	run := egobatch.MemoRun(memo, func(arg int) int {
+		return 0 // every argument shares one key
+	}, func(ctx context.Context, arg int) (string, *myerrors.Error) {
+		time.Sleep(time.Millisecond * 50) // waiters join while leader runs
+		panic("boom")
+	})
+
+	taskBatch := egobatch.NewTaskBatch[int, string, *myerrors.Error]([]int{0, 1, 2})
+	taskBatch.SetGlide(true)
+	taskBatch.SetWaPanic(func(recovered any, stack []byte) *myerrors.Error {
+		return myerrors.ErrorServiceError("panic: %v", recovered)
+	})
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	taskBatch.EgoRun(ego, run)
+	myassert.NoError(t, ego.Wait())
+
+	require.Len(t, taskBatch.Tasks.WaTasks(), 3) // coalesced waiters fail like the leader
+	require.Equal(t, 2, memo.Stats().Coalesced)
+	require.Equal(t, 0, memo.Len()) // panic not cached
+}
+
+func TestMemo_Get_WaiterContext(t *testing.T) {
+	memo := egobatch.NewMemo[string, int, *myerrors.Error](time.Minute, 100)
+	memo.SetWaTTL(time.Minute)
+	memo.SetWaCtx(func(err error) *myerrors.Error {
+		return myerrors.ErrorWrongContext("ctx: %v", err)
+	})
+	var calls atomic.Int64
+	run := func(ctx context.Context, key string) (int, *myerrors.Error) {
+		calls.Add(1)
+		select {
+		case <-ctx.Done():
+			return 0, myerrors.ErrorWrongContext("ctx: %v", ctx.Err())
+		case <-time.After(time.Millisecond * 200):
+			return len(key), nil
+		}
+	}
+
+	// Leader gets cancelled, its context error must not reach the waiter nor the cache
+	// 领头调用被取消，其上下文错误不能传给等待者，也不能被缓存
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderDone := make(chan *myerrors.Error, 1)
+	go func() {
+		_, erx := memo.Get(leaderCtx, "abc", run)
+		leaderDone <- erx
+	}()
+	time.Sleep(time.Millisecond * 20)
+
+	// Waiter with own short timeout returns at its deadline instead of the leader's end
+	// 带自身短超时的等待者在自己的截止时间返回，而不是等领头调用结束
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), time.Millisecond*30)
+	defer shortCancel()
+	startTime := time.Now()
+	_, erx := memo.Get(shortCtx, "abc", run)
+	require.True(t, myerrors.IsWrongContext(erx))
+	require.Less(t, time.Since(startTime), time.Millisecond*150)
+	require.Equal(t, int64(1), calls.Load()) // waiter converted own context error without invoking run
+
+	waiterDone := make(chan *myerrors.Error, 1)
+	var waiterRes atomic.Int64
+	go func() {
+		res, erx := memo.Get(context.Background(), "abc", run)
+		waiterRes.Store(int64(res))
+		waiterDone <- erx
+	}()
+	time.Sleep(time.Millisecond * 20)
+	cancel()
+	require.True(t, myerrors.IsWrongContext(<-leaderDone))
+	myassert.NoError(t, <-waiterDone)
+	require.Equal(t, int64(3), waiterRes.Load()) // waiter ran again on own context
+	require.Equal(t, int64(2), calls.Load())
+	require.Equal(t, 1, memo.Len()) // only the success got cached
+}
+
+func TestMemo_Get_WaiterContextNoWaCtx(t *testing.T) {
+	memo := egobatch.NewMemo[string, int, *myerrors.Error](time.Minute, 100)
+	var calls atomic.Int64
+	run := func(ctx context.Context, key string) (int, *myerrors.Error) {
+		calls.Add(1)
+		time.Sleep(time.Millisecond * 50)
+		return len(key), nil
+	}
+
+	go func() {
+		_, _ = memo.Get(context.Background(), "abc", run)
+	}()
+	time.Sleep(time.Millisecond * 10)
+
+	// Without waCtx the waiter waits for the leader instead of invoking the backend again
+	// 未设置 waCtx 时等待者等待领头调用，而不是再次调用后端
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer shortCancel()
+	res, erx := memo.Get(shortCtx, "abc", run)
+	myassert.NoError(t, erx)
+	require.Equal(t, 3, res)
+	require.Equal(t, int64(1), calls.Load())
+	require.Equal(t, 1, memo.Stats().Coalesced)
+}
@@ -0,0 +1,353 @@
+package egobatch
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yyle88/egobatch/internal/constraint"
+)
+
+// OutcomeTree is hierarchical outcome node that nested TaskBatch runs attach to
+// Task nodes record own status, error, run time and custom numeric values
+// Rollup aggregates them from leaves up, so a failure deep down stays visible on each ancestor
+// Safe to grow and update from concurrent tasks
+//
+// OutcomeTree 是嵌套 TaskBatch 执行可以挂载的层级结果节点
+// 任务节点记录自身的状态、错误、执行时长和自定义数值
+// Rollup 从叶子向上汇总，使深层的失败在每个祖先节点上都可见
+// 可以被并发任务安全地扩展和更新
+type OutcomeTree struct {
+	Name string // Node name // 节点名称
+
+	mutex    sync.Mutex         // Guards fields below // 保护下面的字段
+	status   TaskStatus         // Task status, empty on grouping node // 任务状态，分组节点为空
+	erx      error              // Task error, nil when not failed // 任务错误，未失败时为 nil
+	elapsed  time.Duration      // Task run time // 任务执行时长
+	values   map[string]float64 // Custom numeric values // 自定义数值
+	children []*OutcomeTree     // Child nodes in creation order // 按创建顺序排列的子节点
+}
+
+// outcomeSnapshot holds node fields read under mutex
+// outcomeSnapshot 保存在锁内读取的节点字段
+type outcomeSnapshot struct {
+	status  TaskStatus         // Task status // 任务状态
+	erx     error              // Task error // 任务错误
+	elapsed time.Duration      // Task run time // 任务执行时长
+	values  map[string]float64 // Copy of custom values // 自定义数值的副本
+	leaf    bool               // No child nodes // 没有子节点
+}
+
+// snapshot reads node fields under mutex
+// snapshot 在锁内读取节点字段
+func (node *OutcomeTree) snapshot() *outcomeSnapshot {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+	return &outcomeSnapshot{
+		status:  node.status,
+		erx:     node.erx,
+		elapsed: node.elapsed,
+		values:  maps.Clone(node.values),
+		leaf:    len(node.children) == 0,
+	}
+}
+
+// NewOutcomeTree creates grouping node with name
+// NewOutcomeTree 使用名称创建分组节点
+func NewOutcomeTree(name string) *OutcomeTree {
+	return &OutcomeTree{Name: name}
+}
+
+// Child appends child grouping node with name and returns it
+// Child 追加给定名称的子分组节点并返回
+func (node *OutcomeTree) Child(name string) *OutcomeTree {
+	return node.attach(NewOutcomeTree(name))
+}
+
+// attach appends child node and returns it
+// attach 追加子节点并返回
+func (node *OutcomeTree) attach(child *OutcomeTree) *OutcomeTree {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+	node.children = append(node.children, child)
+	return child
+}
+
+// Status returns task status, empty on grouping node
+// Status 返回任务状态，分组节点为空
+func (node *OutcomeTree) Status() TaskStatus {
+	return node.snapshot().status
+}
+
+// Erx returns task error, nil when not failed
+// Erx 返回任务错误，未失败时为 nil
+func (node *OutcomeTree) Erx() error {
+	return node.snapshot().erx
+}
+
+// Value returns custom numeric value on node with presence flag
+// Value 返回节点上的自定义数值及是否存在
+func (node *OutcomeTree) Value(key string) (float64, bool) {
+	value, ok := node.snapshot().values[key]
+	return value, ok
+}
+
+// Children returns child nodes in creation order
+// Children 按创建顺序返回子节点
+func (node *OutcomeTree) Children() []*OutcomeTree {
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+	return slices.Clone(node.children)
+}
+
+// SetValue records custom numeric value on node, nil node means no tree attached and does nothing
+// SetValue 在节点上记录自定义数值，nil 节点表示未挂载结果树，不做任何操作
+func (node *OutcomeTree) SetValue(key string, value float64) {
+	if node == nil {
+		return
+	}
+	node.mutex.Lock()
+	defer node.mutex.Unlock()
+	if node.values == nil {
+		node.values = map[string]float64{}
+	}
+	node.values[key] = value
+}
+
+// Walk visits nodes depth-first, parent before children, depth zero on node itself
+// Walk 深度优先访问节点，父节点先于子节点，节点自身深度为零
+func (node *OutcomeTree) Walk(visit func(node *OutcomeTree, depth int)) {
+	var walk func(current *OutcomeTree, depth int)
+	walk = func(current *OutcomeTree, depth int) {
+		visit(current, depth)
+		for _, child := range current.Children() {
+			walk(child, depth+1)
+		}
+	}
+	walk(node, 0)
+}
+
+// OutcomeRollup aggregates task outcomes in subtree, node itself included
+// OutcomeRollup 汇总子树中的任务结果，包含节点自身
+type OutcomeRollup struct {
+	Ok      int                   // Succeeded task nodes // 成功的任务节点数
+	Wa      int                   // Failed and cancelled task nodes // 失败和取消的任务节点数
+	Skipped int                   // Skipped task nodes // 跳过的任务节点数
+	Pending int                   // Task nodes not settled // 尚未确定结果的任务节点数
+	Elapsed time.Duration         // Run time summed over leaf task nodes, nested run time not counted twice // 叶子任务节点的执行时长之和，嵌套时长不重复计算
+	Values  map[string]*Aggregate // Custom values aggregated by key // 按键汇总的自定义数值
+}
+
+// Aggregate summarizes custom numeric values with one key
+// Aggregate 汇总同一个键的自定义数值
+type Aggregate struct {
+	Count int     // Values count // 数值个数
+	Sum   float64 // Values sum // 数值之和
+	Min   float64 // Min value // 最小值
+	Max   float64 // Max value // 最大值
+}
+
+// Avg returns mean value, zero when no value
+// Avg 返回平均值，无数值时为零
+func (a *Aggregate) Avg() float64 {
+	if a.Count == 0 {
+		return 0
+	}
+	return a.Sum / float64(a.Count)
+}
+
+// add folds one value into aggregate
+// add 将一个数值合并到汇总中
+func (a *Aggregate) add(value float64) {
+	if a.Count == 0 || value < a.Min {
+		a.Min = value
+	}
+	if a.Count == 0 || value > a.Max {
+		a.Max = value
+	}
+	a.Count++
+	a.Sum += value
+}
+
+// merge folds aggregate of another subtree into aggregate
+// merge 将另一棵子树的汇总合并到汇总中
+func (a *Aggregate) merge(other *Aggregate) {
+	if other.Count == 0 {
+		return
+	}
+	if a.Count == 0 || other.Min < a.Min {
+		a.Min = other.Min
+	}
+	if a.Count == 0 || other.Max > a.Max {
+		a.Max = other.Max
+	}
+	a.Count += other.Count
+	a.Sum += other.Sum
+}
+
+// merge folds rollup of child subtree into rollup
+// merge 将子树的汇总合并到汇总中
+func (rollup *OutcomeRollup) merge(other *OutcomeRollup) {
+	rollup.Ok += other.Ok
+	rollup.Wa += other.Wa
+	rollup.Skipped += other.Skipped
+	rollup.Pending += other.Pending
+	rollup.Elapsed += other.Elapsed
+	for key, value := range other.Values {
+		aggregate, ok := rollup.Values[key]
+		if !ok {
+			aggregate = &Aggregate{}
+			rollup.Values[key] = aggregate
+		}
+		aggregate.merge(value)
+	}
+}
+
+// Rollup aggregates outcomes from leaves up to node
+// Rollup 从叶子向上汇总到节点的结果
+func (node *OutcomeTree) Rollup() *OutcomeRollup {
+	return node.rollups(nil)
+}
+
+// rollups computes rollup of node in one post-order pass, recording each subtree rollup in visit when not nil
+// rollups 通过一次后序遍历计算节点的汇总，visit 不为 nil 时记录每棵子树的汇总
+func (node *OutcomeTree) rollups(visit map[*OutcomeTree]*OutcomeRollup) *OutcomeRollup {
+	snapshot := node.snapshot()
+	rollup := &OutcomeRollup{Values: map[string]*Aggregate{}}
+	switch snapshot.status {
+	case TaskStatusSucceeded:
+		rollup.Ok++
+	case TaskStatusFailed, TaskStatusCancelled:
+		rollup.Wa++
+	case TaskStatusSkipped:
+		rollup.Skipped++
+	case TaskStatusPending, TaskStatusRunning:
+		rollup.Pending++
+	}
+	for key, value := range snapshot.values {
+		aggregate := &Aggregate{}
+		aggregate.add(value)
+		rollup.Values[key] = aggregate
+	}
+	children := node.Children()
+	if len(children) == 0 {
+		rollup.Elapsed = snapshot.elapsed
+	}
+	for _, child := range children {
+		rollup.merge(child.rollups(visit))
+	}
+	if visit != nil {
+		visit[node] = rollup
+	}
+	return rollup
+}
+
+// Render returns indented text tree, each line showing node outcome with subtree rollup
+// Render 返回缩进的文本树，每行显示节点结果及其子树汇总
+func (node *OutcomeTree) Render() string {
+	rollups := map[*OutcomeTree]*OutcomeRollup{}
+	node.rollups(rollups)
+	var builder strings.Builder
+	node.Walk(func(current *OutcomeTree, depth int) {
+		snapshot := current.snapshot()
+		rollup, ok := rollups[current]
+		if !ok {
+			rollup = current.Rollup() // Attached after roll-up pass by concurrent task // 在汇总遍历之后由并发任务挂载
+		}
+		builder.WriteString(strings.Repeat("  ", depth))
+		builder.WriteString(current.Name)
+		if snapshot.status != "" {
+			builder.WriteString(fmt.Sprintf(" [%s %s]", snapshot.status, snapshot.elapsed.Round(time.Millisecond)))
+		}
+		builder.WriteString(fmt.Sprintf(" ok=%d wa=%d", rollup.Ok, rollup.Wa))
+		if rollup.Skipped > 0 {
+			builder.WriteString(fmt.Sprintf(" skipped=%d", rollup.Skipped))
+		}
+		for _, key := range slices.Sorted(maps.Keys(rollup.Values)) {
+			builder.WriteString(fmt.Sprintf(" %s.avg=%g", key, rollup.Values[key].Avg()))
+		}
+		if snapshot.erx != nil {
+			builder.WriteString(fmt.Sprintf(" erx=%q", snapshot.erx.Error()))
+		}
+		builder.WriteString("\n")
+	})
+	return builder.String()
+}
+
+// outcomeKey is context key carrying the node of running task
+// outcomeKey 是携带正在执行任务节点的上下文键
+type outcomeKey struct{}
+
+// OutcomeFrom returns node of running task from context, nil when batch has no tree attached
+// Run uses it to record values and to attach nested batches
+//
+// OutcomeFrom 从上下文返回正在执行任务的节点，批量未挂载结果树时为 nil
+// run 使用它记录数值以及挂载嵌套批量
+func OutcomeFrom(ctx context.Context) *OutcomeTree {
+	node, _ := ctx.Value(outcomeKey{}).(*OutcomeTree)
+	return node
+}
+
+// outcomeLink attaches tasks of one batch to child nodes of one tree node
+// outcomeLink 将一个批量的任务挂载到一个树节点的子节点上
+type outcomeLink[A any, R any, E ErrorType] struct {
+	node  *OutcomeTree                    // Parent node // 父节点
+	name  func(arg A) string              // Child node name // 子节点名称
+	mutex sync.Mutex                      // Guards nodes // 保护 nodes
+	nodes map[*Task[A, R, E]]*OutcomeTree // Child node by task, entry dropped once task settles // 按任务保存的子节点，任务确定后删除条目
+}
+
+// SetOutcome attaches batch to tree node, each task gets child node named by name
+// Run finds its own node via OutcomeFrom(ctx), a nested batch attaches there with SetOutcome again
+// Nil node detaches the batch
+//
+// SetOutcome 将批量挂载到树节点，每个任务获得以 name 命名的子节点
+// run 通过 OutcomeFrom(ctx) 获取自己的节点，嵌套批量再次使用 SetOutcome 挂载到该节点
+// nil 节点表示取消挂载
+func (t *TaskBatch[A, R, E]) SetOutcome(node *OutcomeTree, name func(arg A) string) {
+	if node == nil {
+		t.outcome = nil
+		return
+	}
+	link := &outcomeLink[A, R, E]{node: node, name: name, nodes: map[*Task[A, R, E]]*OutcomeTree{}}
+	for _, task := range t.Tasks {
+		link.child(task) // Child nodes in argument order // 子节点按参数顺序排列
+	}
+	t.outcome = link
+}
+
+// child returns node of task, creating it on first use
+// child 返回任务的节点，首次使用时创建
+func (link *outcomeLink[A, R, E]) child(task *Task[A, R, E]) *OutcomeTree {
+	link.mutex.Lock()
+	defer link.mutex.Unlock()
+	child, ok := link.nodes[task]
+	if !ok {
+		child = link.node.attach(&OutcomeTree{Name: link.name(task.Arg), status: TaskStatusPending})
+		link.nodes[task] = child
+	}
+	return child
+}
+
+// record copies settled task outcome onto its node and drops the task entry
+// The node holds all the tree needs, so the task pointer is not kept past settling
+//
+// record 将已确定的任务结果复制到其节点并删除该任务的条目
+// 节点已保存树所需的全部内容，因此任务确定后不再保留任务指针
+func (link *outcomeLink[A, R, E]) record(child *OutcomeTree, task *Task[A, R, E]) {
+	child.mutex.Lock()
+	child.status = task.Status
+	child.elapsed = task.Elapsed
+	if !constraint.Pass(task.Erx) {
+		child.erx = task.Erx
+	}
+	child.mutex.Unlock()
+
+	link.mutex.Lock()
+	defer link.mutex.Unlock()
+	delete(link.nodes, task)
+}
@@ -0,0 +1,88 @@
+package egobatch_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestTaskBatch_SetOutcome(t *testing.T) {
+	// Classes hold students, each student gets scores on subjects, subject 2 of student 1 fails
+	// 班级包含学生，每个学生获得各科成绩，学生1的科目2失败
+	root := egobatch.NewOutcomeTree("school")
+	classBatch := egobatch.NewTaskBatch[int, float64, *myerrors.Error]([]int{0, 1})
+	classBatch.SetGlide(true)
+	classBatch.SetOutcome(root, func(classID int) string {
+		return "class-" + strconv.Itoa(classID)
+	})
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	classBatch.EgoRun(ego, func(ctx context.Context, classID int) (float64, *myerrors.Error) {
+		studentBatch := egobatch.NewTaskBatch[int, float64, *myerrors.Error]([]int{0, 1, 2})
+		studentBatch.SetGlide(true)
+		studentBatch.SetOutcome(egobatch.OutcomeFrom(ctx), func(studentID int) string {
+			return "student-" + strconv.Itoa(studentID)
+		})
+		studentEgo := erxgroup.NewGroup[*myerrors.Error](ctx)
+		studentBatch.EgoRun(studentEgo, func(ctx context.Context, studentID int) (float64, *myerrors.Error) {
+			subjectBatch := egobatch.NewTaskBatch[int, float64, *myerrors.Error]([]int{0, 1, 2})
+			subjectBatch.SetGlide(true)
+			subjectBatch.SetOutcome(egobatch.OutcomeFrom(ctx), func(subjectID int) string {
+				return "subject-" + strconv.Itoa(subjectID)
+			})
+			subjectEgo := erxgroup.NewGroup[*myerrors.Error](ctx)
+			subjectBatch.EgoRun(subjectEgo, func(ctx context.Context, subjectID int) (float64, *myerrors.Error) {
+				if studentID == 1 && subjectID == 2 {
+					return 0, myerrors.ErrorServiceError("wrong-db")
+				}
+				time.Sleep(time.Millisecond)
+				score := float64(60 + 10*subjectID + classID)
+				egobatch.OutcomeFrom(ctx).SetValue("score", score)
+				return score, nil
+			})
+			myassert.NoError(t, subjectEgo.Wait())
+			return 0, nil // Student itself succeeds, failed subject stays visible via rollup // 学生本身成功，失败的科目通过汇总可见
+		})
+		myassert.NoError(t, studentEgo.Wait())
+		return 0, nil
+	})
+	myassert.NoError(t, ego.Wait())
+	t.Log("\n" + root.Render())
+
+	rollup := root.Rollup()
+	require.Equal(t, 2+6+16, rollup.Ok)
+	require.Equal(t, 2, rollup.Wa)
+	require.Equal(t, 0, rollup.Pending)
+	require.GreaterOrEqual(t, rollup.Elapsed, 16*time.Millisecond)
+	require.Equal(t, 16, rollup.Values["score"].Count)
+	require.Equal(t, float64(60), rollup.Values["score"].Min)
+	require.Equal(t, float64(81), rollup.Values["score"].Max)
+
+	classes := root.Children()
+	require.Len(t, classes, 2)
+	require.Equal(t, "class-1", classes[1].Name)
+	require.Equal(t, egobatch.TaskStatusSucceeded, classes[1].Status())
+	require.Equal(t, 1, classes[1].Rollup().Wa) // Failed subject visible at class level // 失败的科目在班级层级可见
+
+	subject := classes[1].Children()[1].Children()[2]
+	require.Equal(t, "subject-2", subject.Name)
+	require.Equal(t, egobatch.TaskStatusFailed, subject.Status())
+	require.ErrorContains(t, subject.Erx(), "wrong-db")
+	_, ok := subject.Value("score")
+	require.False(t, ok)
+	render := root.Render()
+	require.Contains(t, render, `subject-2 [FAILED`)
+	require.Regexp(t, `class-1 \[SUCCEEDED [^\]]+\] ok=12 wa=1 score\.avg=`, render) // Subtree rollup on each line // 每行显示子树汇总
+}
+
+func TestOutcomeFrom_NoTree(t *testing.T) {
+	node := egobatch.OutcomeFrom(context.Background())
+	require.Nil(t, node)
+	node.SetValue("score", 1) // Nil node does nothing // nil 节点不做任何操作
+}
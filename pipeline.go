@@ -0,0 +1,204 @@
+package egobatch
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/constraint"
+	"github.com/yyle88/must"
+	"github.com/yyle88/must/mustnum"
+)
+
+// Stage configures one pipeline stage turning argument A into next stage arguments []B
+// Each stage runs as own TaskBatch with own concurrency, glide mode and context error conversion
+//
+// Stage 配置流水线的一个阶段，将参数 A 转换为下一阶段的参数 []B
+// 每个阶段作为独立的 TaskBatch 执行，拥有各自的并发数、平滑模式和上下文错误转换
+type Stage[A any, B any, E ErrorType] struct {
+	Run   func(ctx context.Context, arg A) ([]B, E) // Stage logic // 阶段逻辑
+	Limit int                                       // Concurrency limit, non-positive means no limit // 并发限制，非正数表示不限制
+	Glide bool                                      // Glide mode flag, false stops the pipeline on first error // 平滑模式标志，false 时第一个错误停止流水线
+	WaCtx func(err error) E                         // Context error conversion, nil means tasks get skipped // 上下文错误转换，nil 表示任务被跳过
+	Name  func(arg A) string                        // Outcome node name, nil means fmt.Sprint of argument // 结果节点名称，nil 表示使用参数的 fmt.Sprint
+}
+
+// NewStage creates glide mode stage with run and concurrency limit
+// NewStage 使用 run 和并发限制创建平滑模式的阶段
+func NewStage[A any, B any, E ErrorType](run func(ctx context.Context, arg A) ([]B, E), limit int) *Stage[A, B, E] {
+	return &Stage[A, B, E]{
+		Run:   run,
+		Limit: limit,
+		Glide: true,
+	}
+}
+
+// stageRun is type-erased stage run over arguments []A, each argument with own parent node
+// stageRun 是擦除类型的阶段执行函数，参数为 []A，每个参数有各自的父节点
+type stageRun func(ctx context.Context, args any, parents []*OutcomeTree) *stageResult
+
+// stageResult holds outcome of one stage run
+// stageResult 保存一个阶段执行的结果
+type stageResult struct {
+	tasks   any            // Stage tasks Tasks[A, []B, E] // 阶段任务 Tasks[A, []B, E]
+	nodes   []*OutcomeTree // Outcome node of each task // 每个任务的结果节点
+	outputs any            // Results []B of successful tasks flattened in task order // 成功任务的结果 []B 按任务顺序展开
+	parents []*OutcomeTree // Node of the producing task on each output // 每个输出对应的产生任务的节点
+	erx     error          // First error of fail-fast stage // 快速失败阶段的第一个错误
+}
+
+// Pipeline chains typed stages, argument type A on first stage and output type Z on last stage
+// Stages run one after another, each stage batching arguments produced by every successful task of the previous stage
+//
+// Pipeline 串联带类型的阶段，第一阶段参数类型为 A，最后阶段输出类型为 Z
+// 阶段依次执行，每个阶段批量处理上一阶段所有成功任务产生的参数
+type Pipeline[A any, Z any] struct {
+	stages []stageRun // Type-erased stage runs // 擦除类型的阶段执行函数
+}
+
+// NewPipeline creates pipeline starting with stage
+// NewPipeline 创建以 stage 开始的流水线
+func NewPipeline[A any, B any, E ErrorType](stage *Stage[A, B, E]) *Pipeline[A, B] {
+	return &Pipeline[A, B]{
+		stages: []stageRun{newStageRun(stage)},
+	}
+}
+
+// Then appends stage taking outputs of pipeline as arguments, returns new pipeline
+// Function instead of method since Go methods cannot introduce type parameters
+//
+// Then 追加以流水线输出作为参数的阶段，返回新的流水线
+// 使用函数而不是方法，因为 Go 方法不能引入类型参数
+func Then[A any, B any, C any, E ErrorType](pipeline *Pipeline[A, B], stage *Stage[B, C, E]) *Pipeline[A, C] {
+	stages := append(pipeline.stages[:len(pipeline.stages):len(pipeline.stages)], newStageRun(stage))
+	return &Pipeline[A, C]{
+		stages: stages,
+	}
+}
+
+// Run executes stages on args and returns outcome tree
+// Each task gets an OutcomeTree node under the node of the task producing its argument, first stage under tree.Root
+// Run of a stage finds its node via OutcomeFrom(ctx), so values and nested batches attach there
+// Error is the first error of a fail-fast stage, later stages then do not run
+//
+// Run 在 args 上执行各阶段并返回结果树
+// 每个任务在产生其参数的任务节点下获得一个 OutcomeTree 节点，第一阶段位于 tree.Root 下
+// 阶段的 run 通过 OutcomeFrom(ctx) 获取自己的节点，数值和嵌套批量挂载在该节点
+// 错误为快速失败阶段的第一个错误，此时后续阶段不再执行
+func (p *Pipeline[A, Z]) Run(ctx context.Context, args []A) (*PipelineTree[A, Z], error) {
+	tree := &PipelineTree[A, Z]{Root: NewOutcomeTree("pipeline"), stages: len(p.stages)}
+	parents := make([]*OutcomeTree, 0, len(args))
+	for range args {
+		parents = append(parents, tree.Root)
+	}
+	var stageArgs any = args
+	for _, stageRun := range p.stages {
+		result := stageRun(ctx, stageArgs, parents)
+		tree.results = append(tree.results, result)
+		if result.erx != nil {
+			return tree, result.erx
+		}
+		stageArgs, parents = result.outputs, result.parents
+	}
+	return tree, nil
+}
+
+// newStageRun erases stage types into run over arguments of the stage
+// newStageRun 将阶段类型擦除为在阶段参数上执行的函数
+func newStageRun[A any, B any, E ErrorType](stage *Stage[A, B, E]) stageRun {
+	return func(ctx context.Context, stageArgs any, parents []*OutcomeTree) *stageResult {
+		args, ok := stageArgs.([]A)
+		must.True(ok) // Stage argument type matches previous stage output type // 阶段参数类型与上一阶段输出类型一致
+		name := stage.Name
+		if name == nil {
+			name = func(arg A) string { return fmt.Sprint(arg) }
+		}
+
+		taskBatch := NewTaskBatch[A, []B, E](args)
+		taskBatch.SetGlide(stage.Glide)
+		if stage.WaCtx != nil {
+			taskBatch.SetWaCtx(stage.WaCtx)
+		}
+		link := &outcomeLink[A, []B, E]{name: name, nodes: map[*Task[A, []B, E]]*OutcomeTree{}}
+		nodes := make([]*OutcomeTree, 0, len(args))
+		for idx, task := range taskBatch.Tasks {
+			node := parents[idx].attach(&OutcomeTree{Name: name(task.Arg), status: TaskStatusPending})
+			link.nodes[task] = node
+			nodes = append(nodes, node)
+		}
+		taskBatch.outcome = link
+
+		ego := erxgroup.NewGroup[E](ctx)
+		if stage.Limit > 0 {
+			ego.SetLimit(stage.Limit)
+		}
+		taskBatch.EgoRun(ego, stage.Run)
+		erx := ego.Wait()
+
+		result := &stageResult{tasks: taskBatch.Tasks, nodes: nodes}
+		var outputs []B
+		for idx, task := range taskBatch.Tasks {
+			if task.Status == TaskStatusSucceeded {
+				for _, res := range task.Res {
+					outputs = append(outputs, res)
+					result.parents = append(result.parents, nodes[idx])
+				}
+			}
+		}
+		result.outputs = outputs
+		if !constraint.Pass(erx) {
+			result.erx = erx
+		}
+		return result
+	}
+}
+
+// PipelineTree holds outcome of pipeline run
+// Root is an OutcomeTree grouping node, so Rollup, Render and Walk cover the whole pipeline
+//
+// PipelineTree 保存流水线执行的结果
+// Root 是 OutcomeTree 分组节点，因此 Rollup、Render 和 Walk 覆盖整个流水线
+type PipelineTree[A any, Z any] struct {
+	Root    *OutcomeTree   // Grouping node holding first stage nodes in argument order // 按参数顺序保存第一阶段节点的分组节点
+	stages  int            // Stage count // 阶段数量
+	results []*stageResult // Result of each stage that ran // 每个已执行阶段的结果
+}
+
+// StageNodes returns outcome nodes of stage at index in tree order, nil when stage did not run
+// Nodes line up with StageTasks of the same stage
+//
+// StageNodes 按树的顺序返回给定序号阶段的结果节点，阶段未执行时为 nil
+// 节点与同一阶段的 StageTasks 一一对应
+func (tree *PipelineTree[A, Z]) StageNodes(stageIdx int) []*OutcomeTree {
+	mustnum.Less(stageIdx, tree.stages) // Index bounds check // 索引边界检查
+	if stageIdx >= len(tree.results) {
+		return nil
+	}
+	return tree.results[stageIdx].nodes
+}
+
+// Outputs returns outputs of successful last stage tasks in tree order
+// Outputs 按树的顺序返回最后阶段成功任务的输出
+func (tree *PipelineTree[A, Z]) Outputs() []Z {
+	if len(tree.results) < tree.stages {
+		return nil
+	}
+	outputs, ok := tree.results[tree.stages-1].outputs.([]Z)
+	must.True(ok) // Last stage output type is Z by construction // 最后阶段输出类型由构造保证为 Z
+	return outputs
+}
+
+// StageTasks returns typed tasks of stage at index in tree order, nil when stage did not run
+// Type parameters X, Y and E are the A, B and E of that Stage, A and Z get inferred from tree
+//
+// StageTasks 按树的顺序返回给定序号阶段的带类型任务，阶段未执行时为 nil
+// 类型参数 X、Y 和 E 为该 Stage 的 A、B 和 E，A 和 Z 由 tree 推断
+func StageTasks[X any, Y any, E ErrorType, A any, Z any](tree *PipelineTree[A, Z], stageIdx int) Tasks[X, []Y, E] {
+	mustnum.Less(stageIdx, tree.stages) // Index bounds check // 索引边界检查
+	if stageIdx >= len(tree.results) {
+		return nil
+	}
+	tasks, ok := tree.results[stageIdx].tasks.(Tasks[X, []Y, E])
+	must.True(ok) // Type parameters match the stage types // 类型参数与阶段类型一致
+	return tasks
+}
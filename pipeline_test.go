@@ -0,0 +1,113 @@
+package egobatch_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestPipeline_Run(t *testing.T) {
+	// Step 1: odd numbers fail, even number n produces n/2+1 step 2 arguments
+	// 步骤1：奇数失败，偶数 n 产生 n/2+1 个步骤2参数
+	step1 := egobatch.NewStage(func(ctx context.Context, arg int) ([]string, *myerrors.Error) {
+		if arg%2 == 1 {
+			return nil, myerrors.ErrorServiceError("step-1-wrong-db")
+		}
+		var outputs []string
+		for idx := 0; idx <= arg/2; idx++ {
+			outputs = append(outputs, strconv.Itoa(arg)+"-"+strconv.Itoa(idx))
+		}
+		return outputs, nil
+	}, 3)
+	// Step 2: arguments ending with "-1" fail, others produce one step 3 argument
+	// 步骤2：以 "-1" 结尾的参数失败，其余产生一个步骤3参数
+	step2 := egobatch.NewStage(func(ctx context.Context, arg string) ([]int, *myerrors.Error) {
+		if arg[len(arg)-2:] == "-1" {
+			return nil, myerrors.ErrorServiceError("step-2-wrong-db")
+		}
+		return []int{len(arg)}, nil
+	}, 2)
+	step3 := egobatch.NewStage(func(ctx context.Context, arg int) ([]string, *myerrors.Error) {
+		return []string{"len=" + strconv.Itoa(arg)}, nil
+	}, 1)
+	pipeline := egobatch.Then(egobatch.Then(egobatch.NewPipeline(step1), step2), step3)
+
+	tree, err := pipeline.Run(context.Background(), []int{0, 1, 2, 3, 4})
+	require.NoError(t, err)
+	t.Log("\n" + tree.Root.Render())
+
+	stage1 := egobatch.StageTasks[int, string, *myerrors.Error](tree, 0)
+	require.Len(t, stage1, 5)
+	require.Equal(t, egobatch.TaskStatusFailed, stage1[1].Status)
+	require.True(t, myerrors.IsServiceError(stage1[1].Erx))
+	require.Equal(t, []string{"4-0", "4-1", "4-2"}, stage1[4].Res)
+
+	// Outcome nodes record which parent argument produced them
+	// 结果节点记录由哪个父参数产生
+	nodes1 := tree.StageNodes(0)
+	require.Equal(t, tree.Root.Children(), nodes1)
+	require.Empty(t, nodes1[1].Children())
+	require.Equal(t, "4", nodes1[4].Name)
+	children := nodes1[4].Children()
+	require.Len(t, children, 3)
+	require.Equal(t, "4-1", children[1].Name)
+	require.Equal(t, egobatch.TaskStatusFailed, children[1].Status())
+	require.Empty(t, children[1].Children())
+	require.Len(t, children[2].Children(), 1)
+
+	// Stage 2 args: 0-0, 2-0, 2-1, 4-0, 4-1, 4-2 with two failures
+	// 阶段2参数：0-0、2-0、2-1、4-0、4-1、4-2，其中两个失败
+	stage2 := egobatch.StageTasks[string, int, *myerrors.Error](tree, 1)
+	require.Len(t, stage2, 6)
+	require.Len(t, tree.StageNodes(1), 6)
+	require.Equal(t, "4-1", stage2[4].Arg)
+	require.True(t, myerrors.IsServiceError(stage2[4].Erx))
+	require.Equal(t, []int{3}, stage2[5].Res)
+	require.Len(t, tree.StageNodes(2), 4)
+	require.Equal(t, []string{"len=3", "len=3", "len=3", "len=3"}, tree.Outputs())
+
+	// Rollup covers every stage: 5+6+4 tasks, 2+2 failures
+	// 汇总覆盖所有阶段：5+6+4 个任务，2+2 个失败
+	rollup := tree.Root.Rollup()
+	require.Equal(t, 11, rollup.Ok)
+	require.Equal(t, 4, rollup.Wa)
+}
+
+func TestPipeline_Run_FailFast(t *testing.T) {
+	step1 := egobatch.NewStage(func(ctx context.Context, arg int) ([]int, *myerrors.Error) {
+		return []int{arg, arg + 10}, nil
+	}, 2)
+	step2 := egobatch.NewStage(func(ctx context.Context, arg int) ([]int, *myerrors.Error) {
+		if arg == 11 {
+			return nil, myerrors.ErrorServiceError("step-2-wrong-db")
+		}
+		return []int{arg * 2}, nil
+	}, 1)
+	step2.Glide = false
+	step2.WaCtx = func(err error) *myerrors.Error {
+		return myerrors.ErrorWrongContext("wrong-ctx. error=%v", err)
+	}
+	var invoked bool
+	step3 := egobatch.NewStage(func(ctx context.Context, arg int) ([]int, *myerrors.Error) {
+		invoked = true
+		return []int{arg}, nil
+	}, 1)
+	pipeline := egobatch.Then(egobatch.Then(egobatch.NewPipeline(step1), step2), step3)
+
+	tree, err := pipeline.Run(context.Background(), []int{1, 2})
+	require.ErrorContains(t, err, "step-2-wrong-db")
+	require.False(t, invoked) // Later stages do not run // 后续阶段不再执行
+	require.Empty(t, tree.Outputs())
+	require.Nil(t, egobatch.StageTasks[int, int, *myerrors.Error](tree, 2))
+
+	stage2 := egobatch.StageTasks[int, int, *myerrors.Error](tree, 1)
+	require.Len(t, stage2, 4)
+	require.Equal(t, egobatch.TaskStatusSucceeded, stage2[0].Status)
+	require.Equal(t, egobatch.TaskStatusFailed, stage2[1].Status)
+	require.Equal(t, egobatch.TaskStatusCancelled, stage2[2].Status)
+	require.Equal(t, egobatch.TaskStatusCancelled, tree.StageNodes(1)[2].Status())
+}
@@ -0,0 +1,186 @@
+package egobatch
+
+import (
+	"context"
+	"time"
+
+	"github.com/yyle88/egobatch/erxgroup"
+)
+
+// Runner configures one-call batch execution, A, R and E get inferred once from run
+// Settings get chained on the runner, so a converter on a different error type fails to compile
+// One runner can run many batches and retry their failed tasks, see Tasks.Retry
+//
+// Runner 配置一次调用的批量执行，A、R 和 E 从 run 一次性推断
+// 设置以链式调用配置在 runner 上，因此错误类型不一致的转换函数无法通过编译
+// 一个 runner 可以执行多个批量并重试其失败的任务，参见 Tasks.Retry
+type Runner[A any, R any, E ErrorType] struct {
+	run     func(ctx context.Context, arg A) (R, E) // Task function // 任务函数
+	glide   bool                                    // Glide mode flag // 平滑模式标志
+	limit   int                                     // Concurrent goroutines limit, non-positive means no limit // 并发协程限制，非正数表示不限制
+	waCtx   func(err error) E                       // Context error conversion function // 上下文错误转换函数
+	waPanic func(recovered any, stack []byte) E     // Panic conversion function // panic 转换函数
+	retry   *RetryPolicy[E]                         // Retry policy // 重试策略
+
+	taskTimeout time.Duration           // Per-task timeout // 单任务超时
+	threshold   *Threshold              // Failure threshold // 失败阈值
+	limiter     *erxgroup.Limiter       // Rate limiter // 限流器
+	adaptive    *erxgroup.AdaptiveLimit // Adaptive concurrency // 自适应并发
+	weight      func(arg A) int64       // Task weight function // 任务权重函数
+	waWeight    func(err error) E       // Oversize weight error conversion function // 权重超限错误转换函数
+	priority    func(arg A) int         // Scheduling priority function // 调度优先级函数
+	budget      *erxgroup.Budget        // Shared concurrency budget // 共享并发预算
+}
+
+// NewRunner creates runner on run with default settings: fail-fast and no limit
+// NewRunner 使用默认设置在 run 上创建 runner：快速失败且不限制并发
+func NewRunner[A any, R any, E ErrorType](run func(ctx context.Context, arg A) (R, E)) *Runner[A, R, E] {
+	return &Runner[A, R, E]{run: run}
+}
+
+// WithGlide configures glide mode, see TaskBatch.SetGlide
+// WithGlide 配置平滑模式，参见 TaskBatch.SetGlide
+func (r *Runner[A, R, E]) WithGlide(glide bool) *Runner[A, R, E] {
+	r.glide = glide
+	return r
+}
+
+// WithLimit restricts concurrent goroutines count, see erxgroup.Group.SetLimit
+// WithLimit 限制并发协程数量，参见 erxgroup.Group.SetLimit
+func (r *Runner[A, R, E]) WithLimit(limit int) *Runner[A, R, E] {
+	r.limit = limit
+	return r
+}
+
+// WithWaCtx configures context error conversion function, see TaskBatch.SetWaCtx
+// WithWaCtx 配置上下文错误转换函数，参见 TaskBatch.SetWaCtx
+func (r *Runner[A, R, E]) WithWaCtx(waCtx func(err error) E) *Runner[A, R, E] {
+	r.waCtx = waCtx
+	return r
+}
+
+// WithWaPanic configures panic conversion function, see TaskBatch.SetWaPanic
+// WithWaPanic 配置 panic 转换函数，参见 TaskBatch.SetWaPanic
+func (r *Runner[A, R, E]) WithWaPanic(waPanic func(recovered any, stack []byte) E) *Runner[A, R, E] {
+	r.waPanic = waPanic
+	return r
+}
+
+// WithRetry configures per-task retry policy, see TaskBatch.SetRetry
+// WithRetry 配置单任务重试策略，参见 TaskBatch.SetRetry
+func (r *Runner[A, R, E]) WithRetry(retry *RetryPolicy[E]) *Runner[A, R, E] {
+	r.retry = retry
+	return r
+}
+
+// WithTaskTimeout configures per-task timeout, see TaskBatch.SetTaskTimeout
+// WithTaskTimeout 配置单任务超时，参见 TaskBatch.SetTaskTimeout
+func (r *Runner[A, R, E]) WithTaskTimeout(taskTimeout time.Duration) *Runner[A, R, E] {
+	r.taskTimeout = taskTimeout
+	return r
+}
+
+// WithThreshold configures failure threshold, see TaskBatch.SetThreshold
+// WithThreshold 配置失败阈值，参见 TaskBatch.SetThreshold
+func (r *Runner[A, R, E]) WithThreshold(threshold *Threshold) *Runner[A, R, E] {
+	r.threshold = threshold
+	return r
+}
+
+// WithRateLimit attaches rate limiter on tasks, see TaskBatch.SetRateLimit
+// WithRateLimit 为任务挂载限流器，参见 TaskBatch.SetRateLimit
+func (r *Runner[A, R, E]) WithRateLimit(limiter *erxgroup.Limiter) *Runner[A, R, E] {
+	r.limiter = limiter
+	return r
+}
+
+// WithAdaptiveLimit enables AIMD adaptive concurrency replacing WithLimit, see erxgroup.Group.SetAdaptiveLimit
+// WithAdaptiveLimit 启用 AIMD 自适应并发并替代 WithLimit，参见 erxgroup.Group.SetAdaptiveLimit
+func (r *Runner[A, R, E]) WithAdaptiveLimit(adaptive *erxgroup.AdaptiveLimit) *Runner[A, R, E] {
+	r.adaptive = adaptive
+	return r
+}
+
+// WithWeight configures task weight held on group capacity, see TaskBatch.SetWeight
+// WithWeight 配置任务占用 group 容量的权重，参见 TaskBatch.SetWeight
+func (r *Runner[A, R, E]) WithWeight(weight func(arg A) int64, waWeight func(err error) E) *Runner[A, R, E] {
+	r.weight = weight
+	r.waWeight = waWeight
+	return r
+}
+
+// WithPriority configures scheduling priority, see TaskBatch.SetPriority
+// WithPriority 配置调度优先级，参见 TaskBatch.SetPriority
+func (r *Runner[A, R, E]) WithPriority(priority func(arg A) int) *Runner[A, R, E] {
+	r.priority = priority
+	return r
+}
+
+// WithBudget makes the group draw from shared concurrency budget, see erxgroup.Budget
+// Nested Run and groups created from run context inherit the budget
+//
+// WithBudget 使 group 使用共享并发预算，参见 erxgroup.Budget
+// 从 run 上下文创建的嵌套 Run 和 group 继承该预算
+func (r *Runner[A, R, E]) WithBudget(budget *erxgroup.Budget) *Runner[A, R, E] {
+	r.budget = budget
+	return r
+}
+
+// Run executes run on each argument in one call and returns tasks with first error
+// Builds TaskBatch and erxgroup.Group, applies settings, schedules with EgoRun then waits
+// In glide mode the returned error is zero and failures stay in tasks
+//
+// Run 一次调用对每个参数执行 run，返回任务集合和第一个错误
+// 构建 TaskBatch 和 erxgroup.Group，应用设置，使用 EgoRun 调度后等待
+// 平滑模式下返回的错误为零值，失败记录在任务中
+func (r *Runner[A, R, E]) Run(ctx context.Context, args []A) (Tasks[A, R, E], E) {
+	taskBatch := NewTaskBatch[A, R, E](args)
+	erx := r.runBatch(ctx, taskBatch)
+	return taskBatch.Tasks, erx
+}
+
+// Run executes run on each argument with default settings, see Runner.Run
+// Use NewRunner to chain settings such as glide mode and limit
+//
+// Run 使用默认设置对每个参数执行 run，参见 Runner.Run
+// 使用 NewRunner 链式配置平滑模式和并发限制等设置
+func Run[A any, R any, E ErrorType](ctx context.Context, args []A, run func(ctx context.Context, arg A) (R, E)) (Tasks[A, R, E], E) {
+	return NewRunner(run).Run(ctx, args)
+}
+
+// runBatch applies settings on batch and group, schedules with EgoRun then waits
+// runBatch 将设置应用到批量和 group，使用 EgoRun 调度后等待
+func (r *Runner[A, R, E]) runBatch(ctx context.Context, taskBatch *TaskBatch[A, R, E]) E {
+	taskBatch.SetGlide(r.glide)
+	taskBatch.SetTaskTimeout(r.taskTimeout)
+	taskBatch.SetThreshold(r.threshold)
+	taskBatch.SetRateLimit(r.limiter)
+	if r.waCtx != nil {
+		taskBatch.SetWaCtx(r.waCtx)
+	}
+	if r.waPanic != nil {
+		taskBatch.SetWaPanic(r.waPanic)
+	}
+	if r.retry != nil {
+		taskBatch.SetRetry(r.retry)
+	}
+	if r.weight != nil {
+		taskBatch.SetWeight(r.weight, r.waWeight)
+	}
+	if r.priority != nil {
+		taskBatch.SetPriority(r.priority)
+	}
+	if r.budget != nil {
+		ctx = erxgroup.WithBudget(ctx, r.budget)
+	}
+
+	ego := erxgroup.NewGroup[E](ctx)
+	if r.limit > 0 {
+		ego.SetLimit(r.limit)
+	}
+	if r.adaptive != nil {
+		ego.SetAdaptiveLimit(r.adaptive)
+	}
+	taskBatch.EgoRun(ego, r.run)
+	return ego.Wait()
+}
@@ -0,0 +1,131 @@
+package egobatch_test
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+	"github.com/yyle88/neatjson/neatjsons"
+)
+
+func TestRun(t *testing.T) {
+	var args = []uint64{0, 1, 2, 3, 4, 5}
+	tasks, erx := egobatch.NewRunner(func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg%2 == 0 {
+			return "", myerrors.ErrorServiceError("wrong db")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	}).WithGlide(true).WithLimit(3).Run(context.Background(), args)
+	myassert.NoError(t, erx)
+
+	results := tasks.Flatten(func(arg uint64, erk *myerrors.Error) string {
+		return "wa-" + strconv.FormatUint(arg, 10)
+	})
+	t.Log(neatjsons.S(results))
+	require.Equal(t, []string{"wa-0", "1", "wa-2", "3", "wa-4", "5"}, results)
+}
+
+func TestRun_FailFast(t *testing.T) {
+	var args = []uint64{0, 1, 2, 3, 4, 5}
+	tasks, erx := egobatch.NewRunner(func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg == 0 {
+			return "", myerrors.ErrorServiceError("wrong db")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	}).WithLimit(1).WithWaCtx(func(err error) *myerrors.Error {
+		return myerrors.ErrorWrongContext("wrong-ctx. error=%v", err)
+	}).Run(context.Background(), args)
+	myassert.Error(t, erx)
+	require.True(t, myerrors.IsServiceError(erx))
+
+	require.Len(t, tasks.WaTasks(), 6)
+	require.Len(t, tasks.Cancelled(), 5)
+}
+
+func TestRun_WithWaPanic(t *testing.T) {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second)
+	defer cancelFunc()
+
+	var args = []uint64{0, 1, 2}
+	tasks, erx := egobatch.NewRunner(func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg == 1 {
+			panic("bad record")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	}).WithGlide(true).WithWaPanic(func(recovered any, stack []byte) *myerrors.Error {
+		return myerrors.New("PANIC_ERROR", "panic=%v", recovered)
+	}).Run(ctx, args)
+	myassert.NoError(t, erx)
+
+	require.Len(t, tasks.OkTasks(), 2)
+	require.Len(t, tasks.WaTasks(), 1)
+	require.Equal(t, "PANIC_ERROR", tasks[1].Erx.Code())
+}
+
+func TestRun_WithAdaptiveLimit(t *testing.T) {
+	args := make([]int, 30)
+	for idx := range args {
+		args[idx] = idx
+	}
+	runPeak := func(wrong bool) (egobatch.Tasks[int, int, *myerrors.Error], int64) {
+		adaptive := erxgroup.NewAdaptiveLimit(1, 4)
+		adaptive.SpikeRatio = 0 // Sleep jitter must not count as spike // 休眠抖动不能视为突增
+		var running, peak atomic.Int64
+		tasks, erx := egobatch.NewRunner(func(ctx context.Context, arg int) (int, *myerrors.Error) {
+			peak.Store(max(peak.Load(), running.Add(1)))
+			time.Sleep(2 * time.Millisecond)
+			running.Add(-1)
+			if wrong {
+				return 0, myerrors.ErrorServiceError("wrong-db")
+			}
+			return arg * 2, nil
+		}).WithGlide(true).WithAdaptiveLimit(adaptive).Run(context.Background(), args)
+		myassert.NoError(t, erx)
+		return tasks, peak.Load()
+	}
+
+	// Healthy tasks raise the limit
+	// 健康的任务提高限制
+	tasks, peak := runPeak(false)
+	require.Len(t, tasks.OkTasks(), len(args))
+	require.Greater(t, peak, int64(1))
+
+	// Glide mode failures never reach the group, yet they keep the limit backed off at Min
+	// 平滑模式的失败不会返回给 group，但仍使限制保持回退在 Min
+	tasks, peak = runPeak(true)
+	require.Len(t, tasks.WaTasks(), len(args))
+	require.Equal(t, int64(1), peak)
+}
+
+func TestRun_WithBudget(t *testing.T) {
+	budget := erxgroup.NewBudget(1)
+	var running, peak atomic.Int64
+	tasks, erx := egobatch.NewRunner(func(ctx context.Context, arg int) (int, *myerrors.Error) {
+		// Nested Run inherits the budget through ctx
+		// 嵌套的 Run 通过 ctx 继承预算
+		subTasks, erx := egobatch.NewRunner(func(ctx context.Context, sub int) (int, *myerrors.Error) {
+			peak.Store(max(peak.Load(), running.Add(1)))
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			return arg*10 + sub, nil
+		}).WithLimit(3).Run(ctx, []int{0, 1, 2})
+		if erx != nil {
+			return 0, erx
+		}
+		sum := 0
+		for _, task := range subTasks {
+			sum += task.Res
+		}
+		return sum, nil
+	}).WithLimit(3).WithBudget(budget).Run(context.Background(), []int{0, 1, 2})
+	myassert.NoError(t, erx)
+	require.Equal(t, []int{3, 33, 63}, []int{tasks[0].Res, tasks[1].Res, tasks[2].Res})
+	require.Equal(t, int64(1), peak.Load())
+}
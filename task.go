@@ -8,6 +8,8 @@
 package egobatch
 
 import (
+	"time"
+
 	"github.com/yyle88/egobatch/internal/constraint"
 )
 
@@ -24,9 +26,45 @@ type ErrorType = constraint.ErrorType
 // Task 代表单个任务，包含参数、结果和错误
 // 泛型类型支持任意参数类型 A、结果类型 R 和错误类型 E
 type Task[A any, R any, E ErrorType] struct {
-	Arg A // Task input argument // 任务输入参数
-	Res R // Task result value // 任务结果值
-	Erx E // Task error (nil when success) // 任务错误（成功时为 nil）
+	Arg      A             // Task input argument // 任务输入参数
+	Res      R             // Task result value // 任务结果值
+	Erx      E             // Task error (nil when success) // 任务错误（成功时为 nil）
+	Status   TaskStatus    // Task lifecycle status // 任务生命周期状态
+	Attempts int           // Run invocation count including retries // run 调用次数（包含重试）
+	Waited   time.Duration // Time spent waiting on rate limiter // 等待限流器的时长
+	Elapsed  time.Duration // Run time excluding rate limiter wait // 执行时长（不含限流等待）
+
+	Rounds []*TaskRound[E] // Outcome of each run round, filled by Tasks.Retry // 每轮执行的结果，由 Tasks.Retry 填充
+}
+
+// TaskStatus represents task lifecycle status maintained by TaskBatch
+// Zero value means status not tracked (hand-built task), judged by Erx alone
+//
+// TaskStatus 代表由 TaskBatch 维护的任务生命周期状态
+// 零值表示未跟踪状态（手动构造的任务），仅根据 Erx 判断
+type TaskStatus string
+
+const (
+	TaskStatusPending   TaskStatus = "PENDING"   // Task created but not started // 任务已创建但未开始
+	TaskStatusRunning   TaskStatus = "RUNNING"   // Task run in progress // 任务正在执行
+	TaskStatusSucceeded TaskStatus = "SUCCEEDED" // Task run completed with success // 任务执行成功
+	TaskStatusFailed    TaskStatus = "FAILED"    // Task run returned error // 任务执行返回错误
+	TaskStatusCancelled TaskStatus = "CANCELLED" // Context cancelled before run, error converted via waCtx // 执行前上下文已取消，错误经 waCtx 转换
+	TaskStatusSkipped   TaskStatus = "SKIPPED"   // Context cancelled before run, no waCtx to convert // 执行前上下文已取消，且无 waCtx 转换
+)
+
+// Executed checks if task run reached an end with outcome (success/failure/cancelled)
+// Returns false on pending, running and skipped status
+//
+// Executed 检查任务是否已结束并产生结果（成功/失败/取消）
+// 在等待、运行中和跳过状态时返回 false
+func (status TaskStatus) Executed() bool {
+	switch status {
+	case TaskStatusPending, TaskStatusRunning, TaskStatusSkipped:
+		return false
+	default:
+		return true
+	}
 }
 
 // Tasks is a slice of Task pointers supporting batch operations
@@ -37,14 +75,14 @@ type Task[A any, R any, E ErrorType] struct {
 type Tasks[A any, R any, E ErrorType] []*Task[A, R, E]
 
 // OkTasks filters and returns tasks that completed with success
-// Returns subset of tasks on success
+// Returns subset of tasks on success, excluding tasks that never ran
 //
 // OkTasks 过滤并返回成功完成的任务
-// 返回成功的任务子集
+// 返回成功的任务子集，不包含从未执行的任务
 func (tasks Tasks[A, R, E]) OkTasks() Tasks[A, R, E] {
 	var okTasks Tasks[A, R, E]
 	for _, task := range tasks {
-		if constraint.Pass(task.Erx) {
+		if constraint.Pass(task.Erx) && task.Status.Executed() {
 			okTasks = append(okTasks, task)
 		}
 	}
@@ -84,3 +122,67 @@ func (tasks Tasks[A, R, E]) Flatten(newWaFunc func(arg A, erx E) R) []R {
 	}
 	return results
 }
+
+// NoTasks filters and returns tasks that never ran (pending, running or skipped)
+// Returns subset of tasks without outcome
+//
+// NoTasks 过滤并返回从未执行完成的任务（等待、运行中或跳过）
+// 返回没有结果的任务子集
+func (tasks Tasks[A, R, E]) NoTasks() Tasks[A, R, E] {
+	var noTasks Tasks[A, R, E]
+	for _, task := range tasks {
+		if constraint.Pass(task.Erx) && !task.Status.Executed() {
+			noTasks = append(noTasks, task)
+		}
+	}
+	return noTasks
+}
+
+// Pending filters and returns tasks with pending status
+// Pending 过滤并返回等待状态的任务
+func (tasks Tasks[A, R, E]) Pending() Tasks[A, R, E] {
+	return tasks.filterStatus(TaskStatusPending)
+}
+
+// Skipped filters and returns tasks with skipped status
+// Skipped 过滤并返回跳过状态的任务
+func (tasks Tasks[A, R, E]) Skipped() Tasks[A, R, E] {
+	return tasks.filterStatus(TaskStatusSkipped)
+}
+
+// Cancelled filters and returns tasks with cancelled status
+// Cancelled 过滤并返回取消状态的任务
+func (tasks Tasks[A, R, E]) Cancelled() Tasks[A, R, E] {
+	return tasks.filterStatus(TaskStatusCancelled)
+}
+
+func (tasks Tasks[A, R, E]) filterStatus(status TaskStatus) Tasks[A, R, E] {
+	var results Tasks[A, R, E]
+	for _, task := range tasks {
+		if task.Status == status {
+			results = append(results, task)
+		}
+	}
+	return results
+}
+
+// FlattenWaNo transforms task results into flat slice, handling unexecuted tasks apart
+// Uses newWaFunc to convert failed tasks and newNoFunc to convert tasks that never ran
+// Avoids counting unexecuted tasks as success with zero result
+//
+// FlattenWaNo 将任务结果转换成扁平切片，单独处理未执行的任务
+// 使用 newWaFunc 转换失败的任务，使用 newNoFunc 转换从未执行的任务
+// 避免将未执行的任务当作零值结果的成功任务
+func (tasks Tasks[A, R, E]) FlattenWaNo(newWaFunc func(arg A, erx E) R, newNoFunc func(arg A, status TaskStatus) R) []R {
+	var results = make([]R, 0, len(tasks))
+	for _, task := range tasks {
+		if !constraint.Pass(task.Erx) {
+			results = append(results, newWaFunc(task.Arg, task.Erx))
+		} else if !task.Status.Executed() {
+			results = append(results, newNoFunc(task.Arg, task.Status))
+		} else {
+			results = append(results, task.Res)
+		}
+	}
+	return results
+}
@@ -2,6 +2,9 @@ package egobatch
 
 import (
 	"context"
+	"iter"
+	"runtime/debug"
+	"time"
 
 	"github.com/yyle88/egobatch/erxgroup"
 	"github.com/yyle88/egobatch/internal/constraint"
@@ -21,23 +24,42 @@ type TaskBatch[A any, R any, E ErrorType] struct {
 	Tasks Tasks[A, R, E]    // Task collection with arguments and results // 任务集合，包含参数和结果
 	Glide bool              // Glide mode flag: false=fail-fast, true=independent tasks // 平滑模式标志：false=快速失败，true=独立任务
 	waCtx func(err error) E // Context error conversion function // 上下文错误转换函数
+
+	waPanic func(recovered any, stack []byte) E // Panic conversion function, nil means no recovery // panic 转换函数，nil 表示不恢复
+	retry   *RetryPolicy[E]                     // Retry policy, nil means single attempt // 重试策略，nil 表示仅执行一次
+
+	taskTimeout time.Duration // Per-task timeout, non-positive means no timeout // 单任务超时，非正数表示不超时
+
+	threshold *Threshold       // Failure threshold, nil means glide flag decides // 失败阈值，nil 表示由平滑标志决定
+	counter   thresholdCounter // Completion and failure counters on threshold // 阈值使用的完成数和失败数计数器
+
+	lazyArgs iter.Seq[A]          // Lazy argument source, tasks created on demand instead of Tasks // 惰性参数源，按需创建任务而不使用 Tasks
+	dedup    *dedupState[A, R, E] // Keyed mode state, nil means no dedup // 按键去重状态，nil 表示不去重
+
+	limiter  *erxgroup.Limiter // Rate limiter awaited before each task attempt, nil means no rate limit // 每次任务尝试前等待的限流器，nil 表示不限流
+	bulkhead bulkheadGate[A]   // Per-key concurrency and rate limits, nil means no bulkhead // 按键的并发和速率限制，nil 表示不使用隔板
+
+	weight   func(arg A) int64 // Task weight held on group capacity, nil means weight one // 任务占用 group 容量的权重，nil 表示权重为一
+	waWeight func(err error) E // Oversize weight error conversion function // 权重超限错误转换函数
+
+	priority func(arg A) int // Scheduling priority, higher first, nil means argument order // 调度优先级，高者优先，nil 表示参数顺序
+
+	outcome *outcomeLink[A, R, E] // Outcome tree attachment, nil means no tree // 结果树挂载，nil 表示不挂载
+
+	checkpoint *Checkpoint[A, R, E] // Checkpoint getting settled outcomes, nil means no checkpoint // 接收已确定结果的检查点，nil 表示不使用检查点
 }
 
 // NewTaskBatch creates batch task engine with starting arguments
-// Each argument becomes a task with zero-initialized result and error
+// Each argument becomes a task with zero-initialized result and error in pending status
 // Default glide mode is false (fail-fast mode)
 //
 // NewTaskBatch 使用初始参数创建批量任务处理器
-// 每个参数成为一个任务，结果和错误初始化为零值
+// 每个参数成为一个任务，结果和错误初始化为零值，状态为等待
 // 默认平滑模式是 false（快速失败行为）
 func NewTaskBatch[A any, R any, E ErrorType](args []A) *TaskBatch[A, R, E] {
 	tasks := make([]*Task[A, R, E], 0, len(args))
 	for idx := 0; idx < len(args); idx++ {
-		tasks = append(tasks, &Task[A, R, E]{
-			Arg: args[idx],
-			Res: utils.Zero[R](),
-			Erx: utils.Zero[E](),
-		})
+		tasks = append(tasks, newTask[A, R, E](args[idx]))
 	}
 	return &TaskBatch[A, R, E]{
 		Tasks: tasks,
@@ -45,50 +67,223 @@ func NewTaskBatch[A any, R any, E ErrorType](args []A) *TaskBatch[A, R, E] {
 	}
 }
 
+// newTask creates pending task with zero-initialized result and error
+// newTask 创建等待状态的任务，结果和错误初始化为零值
+func newTask[A any, R any, E ErrorType](arg A) *Task[A, R, E] {
+	return &Task[A, R, E]{
+		Arg:    arg,
+		Res:    utils.Zero[R](),
+		Erx:    utils.Zero[E](),
+		Status: TaskStatusPending,
+	}
+}
+
 // GetRun creates execution function at given index compatible with errgroup.Go
 // Index must be valid (invoking code controls iteration count as basic contract)
 // Returns wrapped function handling context cancellation and error propagation
+// Maintains task status: skipped/cancelled when context is done, else running then succeeded/failed
 //
 // GetRun 在给定索引处创建与 errgroup.Go 兼容的执行函数
 // 索引必须有效（调用者控制迭代次数作为基本约定）
 // 返回处理上下文取消和错误传播的包装函数
+// 维护任务状态：上下文结束时为跳过/取消，否则为运行中然后成功/失败
 func (t *TaskBatch[A, R, E]) GetRun(idx int, run func(ctx context.Context, arg A) (R, E)) func(ctx context.Context) E {
 	mustnum.Less(idx, len(t.Tasks)) // Index bounds check - invoking code must not exceed task count // 索引边界检查 - 调用代码不能超过任务数量
-	task := t.Tasks[idx]
-	return func(ctx context.Context) E {
-		if t.waCtx != nil && ctx.Err() != nil {
+	return t.taskRun(t.Tasks[idx], run)
+}
+
+// taskRun creates execution function on given task, shared by indexed and lazy scheduling
+// taskRun 在给定任务上创建执行函数，由按序号调度和惰性调度共用
+func (t *TaskBatch[A, R, E]) taskRun(task *Task[A, R, E], run func(ctx context.Context, arg A) (R, E)) func(ctx context.Context) E {
+	return t.gatedRun(task, run, false)
+}
+
+// gatedRun creates execution function on given task, reserved means bulkhead key slot already taken by dispatcher
+// gatedRun 在给定任务上创建执行函数，reserved 表示分发者已获取隔板键槽位
+func (t *TaskBatch[A, R, E]) gatedRun(task *Task[A, R, E], run func(ctx context.Context, arg A) (R, E), reserved bool) func(ctx context.Context) E {
+	return t.settleRun(task, func(ctx context.Context) E {
+		if t.limiter != nil {
+			task.Waited, _ = t.limiter.Wait(ctx) // Context error during wait falls into the check below // 等待期间的上下文错误交由下面的检查处理
+		}
+		if t.bulkhead != nil {
+			if reserved {
+				task.Waited += t.bulkhead.wait(ctx, task.Arg) // Slot held by dispatcher, just rate token left // 槽位由分发者持有，仅剩限流令牌
+			} else {
+				release, waited := t.bulkhead.acquire(ctx, task.Arg) // Context error during wait falls into the check below // 等待期间的上下文错误交由下面的检查处理
+				defer release()
+				task.Waited += waited
+			}
+		}
+		if ctx.Err() != nil {
+			if t.waCtx == nil {
+				task.Status = TaskStatusSkipped // No converter: leave error zero and mark task as never ran // 无转换函数：错误保持零值并标记任务从未执行
+				return utils.Zero[E]()
+			}
 			erx := t.waCtx(ctx.Err()) // Convert context error - must return valid error, not fake zero // 转换上下文错误 - 必须返回有效错误，不能是伪造的零值
 			must.False(constraint.Pass(erx))
 			task.Erx = erx
+			task.Status = TaskStatusCancelled
 			if t.Glide {
 				return utils.Zero[E]() // Glide mode: record error but continue processing remaining tasks // 平滑模式：记录错误但继续处理剩余任务
 			}
 			return erx
 		}
-		res, erx := run(ctx, task.Arg) // Execute task - no panic allowed, invoking code must handle panic recovery // 执行任务 - 不允许 panic，调用代码必须处理 panic 恢复
+		task.Status = TaskStatusRunning
+		startTime, waited := time.Now(), task.Waited
+		res, erx := t.dedupRun(ctx, task, run)                        // Execute task - panic recovered only when waPanic is set // 执行任务 - 仅当设置 waPanic 时恢复 panic
+		task.Elapsed = time.Since(startTime) - (task.Waited - waited) // Retry waits count in Waited, not in run time // 重试的等待计入 Waited，不计入执行时长
 		if !constraint.Pass(erx) {
-			task.Erx = erx
-			if t.Glide {
-				return utils.Zero[E]() // Glide mode: record error without canceling context, allowing other tasks to proceed // 平滑模式：记录错误但不取消上下文，允许其他任务继续
-			}
-			return erx
+			return t.failRun(ctx, task, erx)
 		}
 		task.Res = res
+		task.Status = TaskStatusSucceeded
+		if t.threshold != nil {
+			t.counter.record(t.threshold, false)
+		}
 		return utils.Zero[E]()
+	})
+}
+
+// settleRun wraps task closure with outcome tree and checkpoint recording of settled task
+// Task restored as succeeded from checkpoint keeps its result and skips the closure
+//
+// settleRun 为任务闭包包装结果树和检查点对已确定任务的记录
+// 从检查点恢复为成功的任务保留其结果并跳过闭包
+func (t *TaskBatch[A, R, E]) settleRun(task *Task[A, R, E], taskRun func(ctx context.Context) E) func(ctx context.Context) E {
+	return func(ctx context.Context) E {
+		if t.outcome != nil {
+			child := t.outcome.child(task)
+			ctx = context.WithValue(ctx, outcomeKey{}, child)
+			defer t.outcome.record(child, task)
+		}
+		if t.checkpoint != nil {
+			if task.Status == TaskStatusSucceeded {
+				return utils.Zero[E]() // Restored from checkpoint, keep result and skip run // 从检查点恢复，保留结果并跳过执行
+			}
+			defer t.checkpoint.record(task)
+		}
+		return taskRun(ctx)
+	}
+}
+
+// failRun records failure on task and decides error returned to group
+// Threshold when set decides cancellation, else glide flag does
+// Failure gets reported to adaptive limit even when kept off the group
+//
+// failRun 在任务上记录失败并决定返回给 group 的错误
+// 设置阈值时由阈值决定是否取消，否则由平滑标志决定
+// 即使失败不返回给 group，也会报告给自适应限制
+func (t *TaskBatch[A, R, E]) failRun(ctx context.Context, task *Task[A, R, E], erx E) E {
+	erxgroup.ReportFailure(ctx) // Glide mode keeps error off the group, adaptive limit still backs off // 平滑模式下错误不返回给 group，自适应限制仍然回退
+	task.Erx = erx
+	task.Status = TaskStatusFailed
+	if t.threshold != nil {
+		if t.counter.record(t.threshold, true) {
+			return erx // Threshold crossed: return error to cancel remaining tasks // 越过阈值：返回错误以取消剩余任务
+		}
+		return utils.Zero[E]() // Below threshold: record error without canceling context // 未越过阈值：记录错误但不取消上下文
+	}
+	if t.Glide {
+		return utils.Zero[E]() // Glide mode: record error without canceling context, allowing other tasks to proceed // 平滑模式：记录错误但不取消上下文，允许其他任务继续
+	}
+	return erx
+}
+
+// retryRun invokes run with retry policy and records attempt count on task
+// Each attempt gets own timeout, so a timed-out attempt can be retried when policy accepts its error
+// Each retry takes own rate token like the first attempt, so retries stay within the quota
+// Stops retrying when policy refuses or context finishes during backoff or wait, returns final outcome
+//
+// retryRun 按重试策略调用 run 并在任务上记录尝试次数
+// 每次尝试拥有独立的超时，因此策略接受超时错误时超时的尝试可以重试
+// 每次重试与首次尝试一样获取自己的限流令牌，使重试不超出配额
+// 当策略拒绝或退避、等待期间上下文结束时停止重试，返回最终结果
+func (t *TaskBatch[A, R, E]) retryRun(ctx context.Context, task *Task[A, R, E], run func(ctx context.Context, arg A) (R, E)) (R, E) {
+	for attempt := 1; ; attempt++ {
+		task.Attempts = attempt
+		res, erx := t.timeoutRun(ctx, task.Arg, run)
+		if constraint.Pass(erx) || t.retry == nil || !t.retry.canRetry(attempt, erx) {
+			return res, erx
+		}
+		if !sleepCtx(ctx, t.retry.backoff(attempt)) {
+			return res, erx
+		}
+		if !t.retryWait(ctx, task) {
+			return res, erx
+		}
+	}
+}
+
+// retryWait takes rate token and key rate token before a retry attempt and adds wait time to Task.Waited
+// Reports false when context finishes during the wait, first attempt waits in gatedRun instead
+//
+// retryWait 在重试前获取限流令牌和键限流令牌，并将等待时长累加到 Task.Waited
+// 等待期间上下文结束时返回 false，首次尝试改在 gatedRun 中等待
+func (t *TaskBatch[A, R, E]) retryWait(ctx context.Context, task *Task[A, R, E]) bool {
+	if t.limiter != nil {
+		waited, _ := t.limiter.Wait(ctx) // Context error checked below // 上下文错误在下面检查
+		task.Waited += waited
+	}
+	if t.bulkhead != nil {
+		task.Waited += t.bulkhead.wait(ctx, task.Arg) // Key slot still held, just key rate token // 键槽位仍被持有，仅获取键限流令牌
+	}
+	return ctx.Err() == nil
+}
+
+// timeoutRun invokes one attempt of run with own timeout context derived from batch context
+// When attempt hits own deadline its error gets converted via waCtx, siblings stay unaffected
+// Run must respect context to stop at deadline, timeout does not preempt running code
+//
+// timeoutRun 使用从批量上下文派生的独立超时上下文调用 run 的一次尝试
+// 当尝试到达自身截止时间时其错误经 waCtx 转换，不影响其他任务
+// run 必须遵循上下文才能在截止时停止，超时不会抢占正在运行的代码
+func (t *TaskBatch[A, R, E]) timeoutRun(ctx context.Context, arg A, run func(ctx context.Context, arg A) (R, E)) (R, E) {
+	if t.taskTimeout <= 0 {
+		return t.safeRun(ctx, arg, run)
+	}
+	taskCtx, cancelFunc := context.WithTimeout(ctx, t.taskTimeout)
+	defer cancelFunc()
+
+	res, erx := t.safeRun(taskCtx, arg, run)
+	if !constraint.Pass(erx) && t.waCtx != nil && ctx.Err() == nil && taskCtx.Err() != nil {
+		erx = t.waCtx(taskCtx.Err()) // Convert own timeout error - must return valid error, not fake zero // 转换自身超时错误 - 必须返回有效错误，不能是伪造的零值
+		must.False(constraint.Pass(erx))
+	}
+	return res, erx
+}
+
+// safeRun invokes run and converts panic into error E when waPanic is set
+// Without waPanic the panic propagates to invoking code as before
+//
+// safeRun 调用 run，当设置 waPanic 时将 panic 转换为错误 E
+// 未设置 waPanic 时 panic 照常传播给调用代码
+func (t *TaskBatch[A, R, E]) safeRun(ctx context.Context, arg A, run func(ctx context.Context, arg A) (R, E)) (res R, erx E) {
+	if t.waPanic != nil {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				erx = t.waPanic(recovered, debug.Stack()) // Convert panic - must return valid error, not fake zero // 转换 panic - 必须返回有效错误，不能是伪造的零值
+				must.False(constraint.Pass(erx))
+				res = utils.Zero[R]()
+			}
+		}()
 	}
+	return run(ctx, arg)
 }
 
 // EgoRun demonstrates GetRun usage with inversion-of-control pattern
 // When task logic is complex and scheduling logic is simple, pass scheduling engine as argument
 // Auto schedules tasks into the provided errgroup
+// Panics on lazy batch from NewTaskBatchSeq or NewTaskBatchChan, which needs EgoSink
 //
 // EgoRun 演示 GetRun 使用方式，采用控制反转模式
 // 当任务逻辑较重而调度逻辑较轻时，将调度器作为参数传入
 // 自动将所有任务调度到提供的 errgroup 中
+// 对 NewTaskBatchSeq 或 NewTaskBatchChan 创建的惰性批量会 panic，惰性批量需使用 EgoSink
 func (t *TaskBatch[A, R, E]) EgoRun(ego *erxgroup.Group[E], run func(ctx context.Context, arg A) (R, E)) {
-	for idx := 0; idx < len(t.Tasks); idx++ {
-		ego.Go(t.GetRun(idx, run))
-	}
+	must.True(t.lazyArgs == nil) // Lazy batch keeps Tasks empty, schedule it with EgoSink // 惰性批量的 Tasks 为空，需使用 EgoSink 调度
+	t.dispatch(t.order(), func(idx int, release func()) {
+		t.egoGoKeyed(ego, t.Tasks[idx], run, release, nil) // Key-aware dispatch keeps saturated keys off global slots // 按键感知分发，避免饱和键占用全局槽位
+	})
 }
 
 // SetGlide configures glide mode
@@ -112,3 +307,62 @@ func (t *TaskBatch[A, R, E]) SetGlide(glide bool) {
 func (t *TaskBatch[A, R, E]) SetWaCtx(waCtx func(err error) E) {
 	t.waCtx = waCtx
 }
+
+// SetWaPanic configures panic conversion function
+// Converts recovered panic value with stack into custom error type E
+// Converted error follows glide/fail-fast rules like errors returned by run
+//
+// SetWaPanic 配置 panic 转换函数
+// 将恢复的 panic 值及堆栈转换为自定义错误类型 E
+// 转换后的错误与 run 返回的错误一样遵循平滑/快速失败规则
+func (t *TaskBatch[A, R, E]) SetWaPanic(waPanic func(recovered any, stack []byte) E) {
+	t.waPanic = waPanic
+}
+
+// SetRetry configures per-task retry policy
+// Retryable errors get retried with backoff before being recorded as task failure
+//
+// SetRetry 配置单任务重试策略
+// 可重试的错误会在记录为任务失败前按退避策略重试
+func (t *TaskBatch[A, R, E]) SetRetry(retry *RetryPolicy[E]) {
+	t.retry = retry
+}
+
+// SetTaskTimeout configures per-task timeout
+// Each task attempt runs with own context.WithTimeout child of the group context
+// Timeout errors get converted via waCtx and do not cancel siblings in glide mode
+//
+// SetTaskTimeout 配置单任务超时
+// 每次任务尝试使用 group 上下文派生的独立 context.WithTimeout 子上下文运行
+// 超时错误经 waCtx 转换，平滑模式下不会取消其他任务
+func (t *TaskBatch[A, R, E]) SetTaskTimeout(taskTimeout time.Duration) {
+	t.taskTimeout = taskTimeout
+}
+
+// SetThreshold configures failure threshold between glide mode and fail-fast mode
+// When set, glide flag gets ignored: failures get tolerated until threshold crossed
+// Once crossed the error gets returned to cancel context, remaining tasks get converted via waCtx
+//
+// SetThreshold 配置介于平滑模式和快速失败模式之间的失败阈值
+// 设置后忽略平滑标志：在越过阈值前容忍失败
+// 越过后返回错误以取消上下文，剩余任务经 waCtx 转换错误
+func (t *TaskBatch[A, R, E]) SetThreshold(threshold *Threshold) {
+	t.threshold = threshold
+}
+
+// Aborted checks if the failure threshold got crossed
+// Aborted 检查是否已越过失败阈值
+func (t *TaskBatch[A, R, E]) Aborted() bool {
+	return t.counter.aborted.Load()
+}
+
+// SetRateLimit attaches token-bucket rate limiter awaited before each task attempt, retries included
+// Wait time gets recorded in Task.Waited apart from run time in Task.Elapsed
+// Context finishing during the wait gets converted via waCtx like other cancellations
+//
+// SetRateLimit 挂载令牌桶限流器，每次任务尝试（包括重试）执行前等待
+// 等待时长记录在 Task.Waited，与记录在 Task.Elapsed 的执行时长分开
+// 等待期间上下文结束会像其他取消一样经 waCtx 转换
+func (t *TaskBatch[A, R, E]) SetRateLimit(limiter *erxgroup.Limiter) {
+	t.limiter = limiter
+}
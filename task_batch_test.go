@@ -207,3 +207,123 @@ func TestTaskBatch_EgoRun(t *testing.T) {
 	t.Log(neatjsons.S(results))
 	require.Equal(t, []string{"wa-0", "1", "wa-2", "3", "wa-4", "5"}, results)
 }
+
+func TestTaskBatch_GetRun_Status(t *testing.T) {
+	var args = []uint64{0, 1, 2, 3}
+	taskBatch := egobatch.NewTaskBatch[uint64, string, *myerrors.Error](args)
+	for _, task := range taskBatch.Tasks {
+		require.Equal(t, egobatch.TaskStatusPending, task.Status)
+	}
+
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	run := func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg == 1 {
+			return "", myerrors.ErrorServiceError("wrong db")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	}
+	myassert.NoError(t, taskBatch.GetRun(0, run)(ctx))
+	myassert.Error(t, taskBatch.GetRun(1, run)(ctx))
+	cancelFunc()
+	myassert.NoError(t, taskBatch.GetRun(2, run)(ctx)) // fail-fast without waCtx: skipped, run not invoked
+
+	require.Equal(t, egobatch.TaskStatusSucceeded, taskBatch.Tasks[0].Status)
+	require.Equal(t, egobatch.TaskStatusFailed, taskBatch.Tasks[1].Status)
+	require.Equal(t, egobatch.TaskStatusSkipped, taskBatch.Tasks[2].Status)
+	require.Equal(t, egobatch.TaskStatusPending, taskBatch.Tasks[3].Status)
+
+	require.Len(t, taskBatch.Tasks.OkTasks(), 1)
+	require.Len(t, taskBatch.Tasks.WaTasks(), 1)
+	require.Len(t, taskBatch.Tasks.NoTasks(), 2)
+
+	taskBatch.SetWaCtx(func(err error) *myerrors.Error {
+		return myerrors.ErrorWrongContext("wrong-ctx. error=%v", err)
+	})
+	myassert.Error(t, taskBatch.GetRun(3, run)(ctx))
+	require.Equal(t, egobatch.TaskStatusCancelled, taskBatch.Tasks[3].Status)
+	require.Len(t, taskBatch.Tasks.Cancelled(), 1)
+}
+
+func TestTaskBatch_SetWaPanic(t *testing.T) {
+	var args = []uint64{0, 1, 2, 3, 4, 5}
+	taskBatch := egobatch.NewTaskBatch[uint64, string, *myerrors.Error](args)
+	taskBatch.SetGlide(true)
+	taskBatch.SetWaPanic(func(recovered any, stack []byte) *myerrors.Error {
+		require.NotEmpty(t, stack)
+		return myerrors.New("PANIC_ERROR", "panic=%v", recovered)
+	})
+
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(3)
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg%3 == 1 {
+			panic("bad record " + strconv.FormatUint(arg, 10))
+		}
+		return strconv.FormatUint(arg, 10), nil
+	})
+	myassert.NoError(t, ego.Wait())
+
+	for idx, task := range taskBatch.Tasks {
+		t.Log("idx:", idx, "arg:", task.Arg, "res:", task.Res, "erx:", task.Erx)
+		if idx%3 == 1 {
+			require.Equal(t, "PANIC_ERROR", task.Erx.Code())
+			require.Equal(t, egobatch.TaskStatusFailed, task.Status)
+		} else {
+			myassert.NoError(t, task.Erx)
+		}
+	}
+	require.Len(t, taskBatch.Tasks.WaTasks(), 2)
+}
+
+func TestTaskBatch_SetRateLimit(t *testing.T) {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Millisecond*100)
+	defer cancelFunc()
+
+	args := make([]uint64, 0, 20)
+	for num := uint64(0); num < 20; num++ {
+		args = append(args, num)
+	}
+	limiter := erxgroup.NewLimiter(100, 1)
+	tasks, erx := egobatch.NewRunner(func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		return strconv.FormatUint(arg, 10), nil
+	}).WithGlide(true).WithRateLimit(limiter).WithWaCtx(func(err error) *myerrors.Error {
+		return myerrors.ErrorWrongContext("wrong-ctx. error=%v", err)
+	}).Run(ctx, args)
+	myassert.NoError(t, erx)
+
+	t.Log(len(tasks.OkTasks()), len(tasks.Cancelled()))
+	require.NotEmpty(t, tasks.OkTasks())
+	require.NotEmpty(t, tasks.Cancelled()) // quota exhausts context deadline, waits converted via waCtx
+	require.Len(t, tasks, len(tasks.OkTasks())+len(tasks.Cancelled()))
+	var delayed int
+	for _, task := range tasks.OkTasks() {
+		if task.Waited > 0 {
+			delayed++
+		}
+	}
+	require.Equal(t, len(tasks.OkTasks())-1, delayed) // just the burst token passes without waiting
+}
+
+func TestTaskBatch_SetRateLimit_Retry(t *testing.T) {
+	taskBatch := egobatch.NewTaskBatch[int, int, *myerrors.Error]([]int{0})
+	taskBatch.SetRateLimit(erxgroup.NewLimiter(50, 1))
+	taskBatch.SetRetry(egobatch.NewRetryPolicy[*myerrors.Error](5, 0))
+
+	var calls int
+	startTime := time.Now()
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg int) (int, *myerrors.Error) {
+		calls++
+		return 0, myerrors.ErrorServiceError("busy")
+	})
+	require.NotNil(t, ego.Wait())
+	require.Equal(t, 5, calls)
+	// Each retry takes own token at 50/s with burst 1: at least 4 intervals of 20ms
+	// 每次重试以 50/s、突发 1 获取自己的令牌：至少 4 个 20ms 间隔
+	require.GreaterOrEqual(t, time.Since(startTime), 75*time.Millisecond)
+
+	task := taskBatch.Tasks[0]
+	require.Equal(t, 5, task.Attempts)
+	require.GreaterOrEqual(t, task.Waited, 75*time.Millisecond)
+	require.Less(t, task.Elapsed, task.Waited) // Waits stay out of run time // 等待不计入执行时长
+}
@@ -0,0 +1,97 @@
+package egobatch
+
+import (
+	"context"
+	"sync"
+)
+
+// DedupStats reports run invocations and saved invocations in keyed mode
+// DedupStats 报告按键去重模式下的实际调用次数和节省的调用次数
+type DedupStats struct {
+	Calls int // Distinct keys that invoked run // 实际调用 run 的不同键数量
+	Saved int // Tasks sharing outcome of another task with same key // 共享同键任务结果的任务数量
+}
+
+// dedupCall holds in-flight or finished outcome shared by tasks with same key
+// dedupCall 保存同键任务共享的进行中或已完成的结果
+type dedupCall[R any, E ErrorType] struct {
+	done chan struct{} // Closed when outcome is ready // 结果就绪时关闭
+	res  R             // Shared result // 共享的结果
+	erx  E             // Shared error // 共享的错误
+
+	panicked any // Panic value of leader run, nil when run returned // 领头任务 run 的 panic 值，正常返回时为 nil
+}
+
+// dedupState tracks calls by key with mutex protection
+// Key extractor adapts typed key onto batch argument type, like keyedBulkhead does
+//
+// dedupState 使用互斥锁按键跟踪调用
+// 键提取函数将带类型的键适配到批量参数类型，与 keyedBulkhead 相同
+type dedupState[A any, R any, E ErrorType] struct {
+	key   func(arg A) any          // Key extractor on argument // 参数的键提取函数
+	mutex sync.Mutex               // Guards calls and stats // 保护调用表和统计
+	calls map[any]*dedupCall[R, E] // Calls by key // 按键保存的调用
+	stats DedupStats               // Dedup statistics // 去重统计
+}
+
+// SetDedupKey configures keyed mode on batch, tasks with same key run just once
+// First task with a key invokes run, others wait and share its result and error
+// Tasks sharing outcome keep zero attempts, see TaskBatch.DedupStats on saved count
+//
+// SetDedupKey 在批量上配置按键去重模式，同键任务只执行一次
+// 每个键的第一个任务调用 run，其他任务等待并共享其结果和错误
+// 共享结果的任务尝试次数保持为零，节省次数参见 TaskBatch.DedupStats
+func SetDedupKey[A any, R any, E ErrorType, K comparable](t *TaskBatch[A, R, E], key func(arg A) K) {
+	t.dedup = &dedupState[A, R, E]{
+		key: func(arg A) any {
+			return key(arg)
+		},
+		calls: map[any]*dedupCall[R, E]{},
+	}
+}
+
+// DedupStats returns dedup statistics, zero when keyed mode is not set
+// DedupStats 返回去重统计，未设置按键模式时为零值
+func (t *TaskBatch[A, R, E]) DedupStats() DedupStats {
+	if t.dedup == nil {
+		return DedupStats{}
+	}
+	t.dedup.mutex.Lock()
+	defer t.dedup.mutex.Unlock()
+	return t.dedup.stats
+}
+
+// dedupRun invokes run once per key and shares outcome with tasks having same key
+// dedupRun 每个键调用一次 run，并与同键任务共享结果
+func (t *TaskBatch[A, R, E]) dedupRun(ctx context.Context, task *Task[A, R, E], run func(ctx context.Context, arg A) (R, E)) (R, E) {
+	if t.dedup == nil {
+		return t.retryRun(ctx, task, run)
+	}
+	key := t.dedup.key(task.Arg)
+
+	t.dedup.mutex.Lock()
+	if call, ok := t.dedup.calls[key]; ok {
+		t.dedup.stats.Saved++
+		t.dedup.mutex.Unlock()
+		<-call.done // Leader holds own goroutine slot, so waiting cannot starve it // 领头任务占有自己的协程槽位，等待不会使其饥饿
+		if call.panicked != nil {
+			panic(call.panicked) // Leader panicked, followers panic alike so no zero outcome passes as success // 领头任务 panic，跟随者同样 panic，避免零值结果被当作成功
+		}
+		return call.res, call.erx
+	}
+	call := &dedupCall[R, E]{done: make(chan struct{})}
+	t.dedup.calls[key] = call
+	t.dedup.stats.Calls++
+	t.dedup.mutex.Unlock()
+
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			call.panicked = recovered // Panic not converted by waPanic, raised again after waking followers // 未经 waPanic 转换的 panic，唤醒跟随者后再次抛出
+			close(call.done)
+			panic(recovered)
+		}
+		close(call.done)
+	}()
+	call.res, call.erx = t.retryRun(ctx, task, run)
+	return call.res, call.erx
+}
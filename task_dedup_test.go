@@ -0,0 +1,81 @@
+package egobatch_test
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestSetDedupKey(t *testing.T) {
+	type Student struct {
+		ID   int
+		Name string
+	}
+	var args []*Student
+	for idx := 0; idx < 12; idx++ {
+		args = append(args, &Student{ID: idx % 4, Name: "student-" + strconv.Itoa(idx)})
+	}
+	taskBatch := egobatch.NewTaskBatch[*Student, string, *myerrors.Error](args)
+	taskBatch.SetGlide(true)
+	egobatch.SetDedupKey(taskBatch, func(arg *Student) int {
+		return arg.ID
+	})
+
+	var calls atomic.Int64
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(3)
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg *Student) (string, *myerrors.Error) {
+		calls.Add(1)
+		time.Sleep(time.Millisecond * 10)
+		if arg.ID == 3 {
+			return "", myerrors.ErrorServiceError("wrong-db")
+		}
+		return "score-" + strconv.Itoa(arg.ID), nil
+	})
+	myassert.NoError(t, ego.Wait())
+
+	require.Equal(t, int64(4), calls.Load())
+	require.Equal(t, egobatch.DedupStats{Calls: 4, Saved: 8}, taskBatch.DedupStats())
+	for idx, task := range taskBatch.Tasks {
+		if idx%4 == 3 {
+			require.True(t, myerrors.IsServiceError(task.Erx))
+			require.Equal(t, egobatch.TaskStatusFailed, task.Status)
+		} else {
+			require.Equal(t, "score-"+strconv.Itoa(idx%4), task.Res)
+			require.Equal(t, egobatch.TaskStatusSucceeded, task.Status)
+		}
+	}
+}
+
+func TestSetDedupKey_GroupPanic(t *testing.T) {
+	taskBatch := egobatch.NewTaskBatch[int, string, *myerrors.Error]([]int{1, 1})
+	taskBatch.SetGlide(true)
+	egobatch.SetDedupKey(taskBatch, func(arg int) int {
+		return arg
+	})
+
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetWaPanic(func(recovered any, stack []byte) *myerrors.Error {
+		return myerrors.ErrorServiceError("panic: %v", recovered)
+	})
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg int) (string, *myerrors.Error) {
+		time.Sleep(time.Millisecond * 20) // follower joins while leader runs
+		panic("boom")
+	})
+	erx := ego.Wait()
+	require.NotNil(t, erx)
+	require.Contains(t, erx.Error(), "panic: boom")
+
+	// Follower panics like the leader instead of taking zero outcome as success
+	// 跟随者与领头任务一样 panic，而不是把零值结果当作成功
+	require.Equal(t, egobatch.DedupStats{Calls: 1, Saved: 1}, taskBatch.DedupStats())
+	require.Empty(t, taskBatch.Tasks.OkTasks())
+}
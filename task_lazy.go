@@ -0,0 +1,85 @@
+package egobatch
+
+import (
+	"context"
+	"iter"
+	"sync/atomic"
+
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/constraint"
+)
+
+// NewTaskBatchSeq creates batch task engine consuming arguments lazily from iter.Seq
+// Tasks stay empty, each task gets created when scheduled and handed to sink via EgoSink
+// EgoRun, EgoStream and EgoStreamOrdered panic on lazy batch, since they schedule Tasks
+// Suits batches with millions of arguments where keeping every task costs too much
+//
+// NewTaskBatchSeq 创建从 iter.Seq 惰性消费参数的批量任务处理器
+// Tasks 保持为空，每个任务在调度时创建并通过 EgoSink 交给 sink
+// EgoRun、EgoStream 和 EgoStreamOrdered 调度的是 Tasks，对惰性批量会 panic
+// 适用于参数数量达到百万级、保留所有任务开销过大的场景
+func NewTaskBatchSeq[A any, R any, E ErrorType](args iter.Seq[A]) *TaskBatch[A, R, E] {
+	return &TaskBatch[A, R, E]{
+		Tasks:    nil,
+		Glide:    false,
+		lazyArgs: args,
+	}
+}
+
+// NewTaskBatchChan creates batch task engine consuming arguments lazily from channel
+// Consumes until channel closes, see NewTaskBatchSeq
+//
+// NewTaskBatchChan 创建从通道惰性消费参数的批量任务处理器
+// 消费直到通道关闭，参见 NewTaskBatchSeq
+func NewTaskBatchChan[A any, R any, E ErrorType](args <-chan A) *TaskBatch[A, R, E] {
+	return NewTaskBatchSeq[A, R, E](func(yield func(A) bool) {
+		for arg := range args {
+			if !yield(arg) {
+				return
+			}
+		}
+	})
+}
+
+// EgoSink schedules tasks into the provided errgroup and hands each settled task to sink
+// Lazy batch pulls next argument just when the group limit admits another goroutine
+// Sink gets invoked from worker goroutines concurrently, it must be safe under concurrency
+// Stops pulling arguments once a task observes done context or returns error to the group
+// Works on indexed batch too, then sink receives tasks kept in Tasks
+//
+// EgoSink 将任务调度到提供的 errgroup 中，并将每个已确定结果的任务交给 sink
+// 惰性批量仅在 group 限制允许启动新协程时拉取下一个参数
+// sink 会被工作协程并发调用，必须是并发安全的
+// 当任务观察到上下文结束或向 group 返回错误时停止拉取参数
+// 同样适用于按序号的批量，此时 sink 接收 Tasks 中保存的任务
+func (t *TaskBatch[A, R, E]) EgoSink(ego *erxgroup.Group[E], run func(ctx context.Context, arg A) (R, E), sink func(idx int, task *Task[A, R, E])) {
+	var stopped atomic.Bool
+	schedule := func(idx int, task *Task[A, R, E], release func()) {
+		t.egoGoKeyed(ego, task, run, release, func(taskRun func(ctx context.Context) E) func(ctx context.Context) E {
+			return func(ctx context.Context) E {
+				erx := taskRun(ctx)
+				if ctx.Err() != nil || !constraint.Pass(erx) {
+					stopped.Store(true) // Batch context done or about to cancel: stop pulling arguments // 批量上下文已结束或即将取消：停止拉取参数
+				}
+				sink(idx, task)
+				return erx
+			}
+		})
+	}
+
+	if t.lazyArgs == nil {
+		t.dispatch(t.order(), func(idx int, release func()) {
+			schedule(idx, t.Tasks[idx], release)
+		})
+		return
+	}
+	idx := 0
+	for arg := range t.lazyArgs {
+		if stopped.Load() {
+			return
+		}
+		task := newTask[A, R, E](arg)
+		schedule(idx, task, t.holdKey(task))
+		idx++
+	}
+}
@@ -0,0 +1,111 @@
+package egobatch_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestNewTaskBatchSeq(t *testing.T) {
+	const count = 1000
+	var pulled atomic.Int64
+	args := func(yield func(uint64) bool) {
+		for num := uint64(0); num < count; num++ {
+			pulled.Add(1)
+			if !yield(num) {
+				return
+			}
+		}
+	}
+	taskBatch := egobatch.NewTaskBatchSeq[uint64, string, *myerrors.Error](args)
+	taskBatch.SetGlide(true)
+
+	var mutex sync.Mutex
+	var okCount, waCount int
+	var maxAhead int64
+	var finished atomic.Int64
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(4)
+	taskBatch.EgoSink(ego, func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg%100 == 7 {
+			return "", myerrors.ErrorServiceError("wrong-db")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	}, func(idx int, task *egobatch.Task[uint64, string, *myerrors.Error]) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		require.Equal(t, uint64(idx), task.Arg)
+		maxAhead = max(maxAhead, pulled.Load()-finished.Load())
+		if task.Status == egobatch.TaskStatusSucceeded {
+			okCount++
+		} else {
+			waCount++
+		}
+		finished.Add(1)
+	})
+	myassert.NoError(t, ego.Wait())
+
+	require.Empty(t, taskBatch.Tasks)
+	require.Equal(t, 990, okCount)
+	require.Equal(t, 10, waCount)
+	require.LessOrEqual(t, maxAhead, int64(5)) // arguments pulled just when group limit admits
+}
+
+func TestNewTaskBatchChan(t *testing.T) {
+	args := make(chan uint64)
+	go func() {
+		defer close(args)
+		for num := uint64(0); num < 100; num++ {
+			args <- num
+		}
+	}()
+	taskBatch := egobatch.NewTaskBatchChan[uint64, string, *myerrors.Error](args)
+
+	var sunk atomic.Int64
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(1)
+	taskBatch.EgoSink(ego, func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg == 10 {
+			return "", myerrors.ErrorServiceError("wrong-db")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	}, func(idx int, task *egobatch.Task[uint64, string, *myerrors.Error]) {
+		sunk.Add(1)
+	})
+	myassert.Error(t, ego.Wait())
+	require.Less(t, sunk.Load(), int64(100)) // fail-fast stops pulling arguments
+	for range args {
+	}
+}
+
+func TestNewTaskBatchChan_EgoRunPanics(t *testing.T) {
+	args := make(chan uint64, 3)
+	args <- 1
+	args <- 2
+	args <- 3
+	close(args)
+	taskBatch := egobatch.NewTaskBatchChan[uint64, string, *myerrors.Error](args)
+
+	var calls atomic.Int64
+	run := func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		calls.Add(1)
+		return strconv.FormatUint(arg, 10), nil
+	}
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	// Lazy batch keeps Tasks empty, indexed schedulers fail loudly instead of processing nothing
+	// 惰性批量的 Tasks 为空，按序号的调度器直接失败而不是什么都不处理
+	require.Panics(t, func() { taskBatch.EgoRun(ego, run) })
+	require.Panics(t, func() { taskBatch.EgoStream(ego, run, 1) })
+	require.Panics(t, func() { taskBatch.EgoStreamOrdered(ego, run, 1) })
+	myassert.NoError(t, ego.Wait())
+	require.Zero(t, calls.Load())
+	require.Len(t, args, 3) // Nothing pulled // 没有拉取任何参数
+}
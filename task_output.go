@@ -13,9 +13,12 @@ import (
 // 类似 Task 但设计为简单数据传输对象，不包含批处理逻辑
 // 用于返回任务结果而不需要完整的 Task 批处理能力
 type TaskOutput[ARG any, RES any, E ErrorType] struct {
-	Arg ARG // Task input argument // 任务输入参数
-	Res RES // Task result value // 任务结果值
-	Erx E   // Task error (nil when success) // 任务错误（成功时为 nil）
+	Arg    ARG        // Task input argument // 任务输入参数
+	Res    RES        // Task result value // 任务结果值
+	Erx    E          // Task error (nil when success) // 任务错误（成功时为 nil）
+	Status TaskStatus // Task lifecycle status // 任务生命周期状态
+
+	Rounds []*TaskRound[E] // Outcome of each run round, filled by Rerun // 每轮执行的结果，由 Rerun 填充
 }
 
 // NewOkTaskOutput creates success task output with result
@@ -25,9 +28,10 @@ type TaskOutput[ARG any, RES any, E ErrorType] struct {
 // 错误字段初始化为零值，表示成功
 func NewOkTaskOutput[ARG any, RES any, E ErrorType](arg ARG, res RES) *TaskOutput[ARG, RES, E] {
 	return &TaskOutput[ARG, RES, E]{
-		Arg: arg,
-		Res: res,
-		Erx: utils.Zero[E](),
+		Arg:    arg,
+		Res:    res,
+		Erx:    utils.Zero[E](),
+		Status: TaskStatusSucceeded,
 	}
 }
 
@@ -38,9 +42,26 @@ func NewOkTaskOutput[ARG any, RES any, E ErrorType](arg ARG, res RES) *TaskOutpu
 // 结果字段初始化为零值，因为任务失败
 func NewWaTaskOutput[ARG any, RES any, E ErrorType](arg ARG, erx E) *TaskOutput[ARG, RES, E] {
 	return &TaskOutput[ARG, RES, E]{
-		Arg: arg,
-		Res: utils.Zero[RES](),
-		Erx: erx,
+		Arg:    arg,
+		Res:    utils.Zero[RES](),
+		Erx:    erx,
+		Status: TaskStatusFailed,
+	}
+}
+
+// NewNoTaskOutput creates task output on task that never ran
+// Result and error fields initialized to zero value, status kept as given
+// Matches newNoFunc signature of Tasks.FlattenWaNo
+//
+// NewNoTaskOutput 为从未执行的任务创建任务输出
+// 结果和错误字段初始化为零值，状态保持传入值
+// 与 Tasks.FlattenWaNo 的 newNoFunc 签名匹配
+func NewNoTaskOutput[ARG any, RES any, E ErrorType](arg ARG, status TaskStatus) *TaskOutput[ARG, RES, E] {
+	return &TaskOutput[ARG, RES, E]{
+		Arg:    arg,
+		Res:    utils.Zero[RES](),
+		Erx:    utils.Zero[E](),
+		Status: status,
 	}
 }
 
@@ -54,14 +75,14 @@ func NewWaTaskOutput[ARG any, RES any, E ErrorType](arg ARG, erx E) *TaskOutput[
 type TaskOutputList[ARG any, RES any, E ErrorType] []*TaskOutput[ARG, RES, E]
 
 // OkList filters and returns outputs that completed with success
-// Returns subset on success
+// Returns subset on success, excluding outputs that never ran
 //
 // OkList 过滤并返回成功完成的输出
-// 返回成功的子集
+// 返回成功的子集，不包含从未执行的输出
 func (rs TaskOutputList[ARG, RES, E]) OkList() TaskOutputList[ARG, RES, E] {
 	var results TaskOutputList[ARG, RES, E]
 	for _, one := range rs {
-		if constraint.Pass(one.Erx) {
+		if constraint.Pass(one.Erx) && one.Status.Executed() {
 			results = append(results, one)
 		}
 	}
@@ -84,14 +105,14 @@ func (rs TaskOutputList[ARG, RES, E]) WaList() TaskOutputList[ARG, RES, E] {
 }
 
 // OkCount counts success task outputs
-// Returns count of outputs on success
+// Returns count of outputs on success, excluding outputs that never ran
 //
 // OkCount 统计成功的任务输出
-// 返回成功的输出数量
+// 返回成功的输出数量，不包含从未执行的输出
 func (rs TaskOutputList[ARG, RES, E]) OkCount() int {
 	var cnt int
 	for _, one := range rs {
-		if constraint.Pass(one.Erx) {
+		if constraint.Pass(one.Erx) && one.Status.Executed() {
 			cnt++
 		}
 	}
@@ -114,14 +135,14 @@ func (rs TaskOutputList[ARG, RES, E]) WaCount() int {
 }
 
 // OkResults extracts result values from success outputs
-// Returns slice containing just results from outputs without errors
+// Returns slice containing just results from executed outputs without errors
 //
 // OkResults 从成功的输出中提取结果值
-// 返回仅包含无错误输出的结果切片
+// 返回仅包含已执行且无错误输出的结果切片
 func (rs TaskOutputList[ARG, RES, E]) OkResults() []RES {
 	var results []RES
 	for _, one := range rs {
-		if constraint.Pass(one.Erx) {
+		if constraint.Pass(one.Erx) && one.Status.Executed() {
 			results = append(results, one.Res)
 		}
 	}
@@ -142,3 +163,40 @@ func (rs TaskOutputList[ARG, RES, E]) WaReasons() []E {
 	}
 	return reasons
 }
+
+// NoList filters and returns outputs of tasks that never ran
+// Returns subset without outcome
+//
+// NoList 过滤并返回从未执行的任务输出
+// 返回没有结果的子集
+func (rs TaskOutputList[ARG, RES, E]) NoList() TaskOutputList[ARG, RES, E] {
+	var results TaskOutputList[ARG, RES, E]
+	for _, one := range rs {
+		if constraint.Pass(one.Erx) && !one.Status.Executed() {
+			results = append(results, one)
+		}
+	}
+	return results
+}
+
+// Output converts task into task output carrying argument, result, error and status
+// Output 将任务转换为任务输出，携带参数、结果、错误和状态
+func (task *Task[A, R, E]) Output() *TaskOutput[A, R, E] {
+	return &TaskOutput[A, R, E]{
+		Arg:    task.Arg,
+		Res:    task.Res,
+		Erx:    task.Erx,
+		Status: task.Status,
+		Rounds: task.Rounds,
+	}
+}
+
+// Outputs converts tasks into task output list
+// Outputs 将任务集合转换为任务输出列表
+func (tasks Tasks[A, R, E]) Outputs() TaskOutputList[A, R, E] {
+	outputs := make(TaskOutputList[A, R, E], 0, len(tasks))
+	for _, task := range tasks {
+		outputs = append(outputs, task.Output())
+	}
+	return outputs
+}
@@ -56,3 +56,27 @@ func TestTaskOutput(t *testing.T) {
 	t.Log(neatjsons.S(ops.OkResults()))
 	t.Log(neatjsons.S(ops.WaReasons()))
 }
+
+func TestTaskOutput_NoList(t *testing.T) {
+	taskBatch := egobatch.NewTaskBatch[int, *egobatch.TaskOutput[int, string, *myerrors.Error], *myerrors.Error]([]int{0, 1, 2})
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(1)
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg int) (*egobatch.TaskOutput[int, string, *myerrors.Error], *myerrors.Error) {
+		if arg == 1 {
+			return nil, myerrors.ErrorServiceError("wrong-db")
+		}
+		return egobatch.NewOkTaskOutput[int, string, *myerrors.Error](arg, strconv.Itoa(arg)), nil
+	})
+	myassert.Error(t, ego.Wait())
+
+	ops := egobatch.TaskOutputList[int, string, *myerrors.Error](taskBatch.Tasks.FlattenWaNo(
+		egobatch.NewWaTaskOutput[int, string, *myerrors.Error],
+		egobatch.NewNoTaskOutput[int, string, *myerrors.Error],
+	))
+	t.Log(neatjsons.S(ops))
+
+	require.Equal(t, 1, ops.OkCount())
+	require.Equal(t, 1, ops.WaCount())
+	require.Len(t, ops.NoList(), 1)
+	require.Equal(t, egobatch.TaskStatusSkipped, ops.NoList()[0].Status)
+}
@@ -0,0 +1,39 @@
+package egobatch
+
+import (
+	"cmp"
+	"slices"
+)
+
+// SetPriority configures scheduling order: higher priority tasks get group slots first
+// Tasks with equal priority keep argument order, results stay at their original index in Tasks
+// Matters when a limit or a deadline cuts the batch short, so the most valuable work finishes first
+// Applies to EgoRun, EgoStream and EgoSink on indexed batch, EgoStreamOrdered keeps argument order
+//
+// SetPriority 配置调度顺序：优先级高的任务先获得 group 槽位
+// 优先级相同的任务保持参数顺序，结果仍保存在 Tasks 中原来的序号处
+// 当限制或截止时间使批量提前结束时很重要，可以让最有价值的工作先完成
+// 作用于 EgoRun、EgoStream 以及按序号批量的 EgoSink，EgoStreamOrdered 保持参数顺序
+func (t *TaskBatch[A, R, E]) SetPriority(priority func(arg A) int) {
+	t.priority = priority
+}
+
+// order returns task indexes in scheduling order
+// order 返回按调度顺序排列的任务序号
+func (t *TaskBatch[A, R, E]) order() []int {
+	indexes := make([]int, len(t.Tasks))
+	for idx := range indexes {
+		indexes[idx] = idx
+	}
+	if t.priority == nil {
+		return indexes
+	}
+	priorities := make([]int, len(t.Tasks))
+	for idx, task := range t.Tasks {
+		priorities[idx] = t.priority(task.Arg)
+	}
+	slices.SortStableFunc(indexes, func(a, b int) int {
+		return cmp.Compare(priorities[b], priorities[a]) // Descending priority // 按优先级降序
+	})
+	return indexes
+}
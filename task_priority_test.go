@@ -0,0 +1,64 @@
+package egobatch_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestTaskBatch_SetPriority(t *testing.T) {
+	args := []int{3, 9, 1, 9, 5, 7}
+	taskBatch := egobatch.NewTaskBatch[int, string, *myerrors.Error](args)
+	taskBatch.SetPriority(func(arg int) int {
+		return arg
+	})
+
+	var mutex sync.Mutex
+	var executed []int
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(1)
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg int) (string, *myerrors.Error) {
+		mutex.Lock()
+		executed = append(executed, arg)
+		mutex.Unlock()
+		return "res-" + strconv.Itoa(arg), nil
+	})
+	myassert.NoError(t, ego.Wait())
+	require.Equal(t, []int{9, 9, 7, 5, 3, 1}, executed)
+
+	// Results stay at original indexes
+	// 结果保存在原来的序号处
+	for idx, task := range taskBatch.Tasks {
+		require.Equal(t, args[idx], task.Arg)
+		require.Equal(t, "res-"+strconv.Itoa(args[idx]), task.Res)
+	}
+}
+
+func TestRun_WithPriority_Deadline(t *testing.T) {
+	// Deadline cuts the batch: the valuable tasks finish, the rest get skipped
+	// 截止时间中断批量：高价值的任务完成，其余被跳过
+	args := []int{1, 2, 3, 100, 200, 300}
+	ctx, cancelFunc := context.WithTimeout(context.Background(), 45*time.Millisecond)
+	defer cancelFunc()
+	tasks, erx := egobatch.NewRunner(func(ctx context.Context, arg int) (int, *myerrors.Error) {
+		time.Sleep(20 * time.Millisecond)
+		return arg, nil
+	}).WithGlide(true).WithLimit(1).WithPriority(func(arg int) int {
+		return arg
+	}).Run(ctx, args)
+	myassert.NoError(t, erx)
+	okTasks := tasks.OkTasks()
+	require.NotEmpty(t, okTasks)
+	for _, task := range okTasks {
+		require.GreaterOrEqual(t, task.Arg, 100)
+	}
+	require.Equal(t, egobatch.TaskStatusSkipped, tasks[0].Status)
+}
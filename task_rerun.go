@@ -0,0 +1,93 @@
+package egobatch
+
+import (
+	"context"
+	"time"
+
+	"github.com/yyle88/egobatch/internal/constraint"
+	"github.com/yyle88/egobatch/internal/utils"
+)
+
+// TaskRound records outcome of one run round on a task
+// Rounds[0] holds the outcome before the first Retry, Rounds[k] the outcome of the k-th Retry
+//
+// TaskRound 记录任务一轮执行的结果
+// Rounds[0] 保存第一次 Retry 之前的结果，Rounds[k] 保存第 k 次 Retry 的结果
+type TaskRound[E ErrorType] struct {
+	Erx      E             // Round error (nil when success) // 本轮错误（成功时为 nil）
+	Status   TaskStatus    // Round status // 本轮状态
+	Attempts int           // Run invocations in round // 本轮 run 调用次数
+	Elapsed  time.Duration // Round run time // 本轮执行时长
+}
+
+// Retry re-executes failed and unexecuted tasks in place, successful tasks stay untouched
+// Runs in own group on ctx with runner settings, so concurrency and glide mode get set per retry
+// Each retried task appends its outcome to Rounds, first appending the outcome it had before
+// Returns first error like Run, zero in glide mode
+//
+// Retry 原地重新执行失败和未执行的任务，成功的任务保持不变
+// 在 ctx 上使用独立的 group 按 runner 的设置执行，因此每次重试可设置并发数和平滑模式
+// 每个重试的任务将其结果追加到 Rounds，首次会先追加重试前的结果
+// 与 Run 一样返回第一个错误，平滑模式下为零值
+func (tasks Tasks[A, R, E]) Retry(ctx context.Context, runner *Runner[A, R, E]) E {
+	var retryTasks Tasks[A, R, E]
+	for _, task := range tasks {
+		if constraint.Pass(task.Erx) && task.Status.Executed() {
+			continue
+		}
+		if len(task.Rounds) == 0 {
+			task.Rounds = append(task.Rounds, task.round())
+		}
+		task.Res = utils.Zero[R]()
+		task.Erx = utils.Zero[E]()
+		task.Status = TaskStatusPending
+		task.Attempts = 0
+		task.Waited = 0
+		task.Elapsed = 0
+		retryTasks = append(retryTasks, task)
+	}
+	if len(retryTasks) == 0 {
+		return utils.Zero[E]()
+	}
+
+	taskBatch := &TaskBatch[A, R, E]{Tasks: retryTasks} // Shares task pointers so outcomes land in place // 共享任务指针，使结果原地写入
+	erx := runner.runBatch(ctx, taskBatch)
+	for _, task := range retryTasks {
+		task.Rounds = append(task.Rounds, task.round())
+	}
+	return erx
+}
+
+// round snapshots current task outcome
+// round 获取当前任务结果的快照
+func (task *Task[A, R, E]) round() *TaskRound[E] {
+	return &TaskRound[E]{
+		Erx:      task.Erx,
+		Status:   task.Status,
+		Attempts: task.Attempts,
+		Elapsed:  task.Elapsed,
+	}
+}
+
+// Rerun re-executes failed and unexecuted outputs in place, see Tasks.Retry
+// Rerun 原地重新执行失败和未执行的输出，参见 Tasks.Retry
+func (rs TaskOutputList[ARG, RES, E]) Rerun(ctx context.Context, runner *Runner[ARG, RES, E]) E {
+	tasks := make(Tasks[ARG, RES, E], 0, len(rs))
+	for _, one := range rs {
+		tasks = append(tasks, &Task[ARG, RES, E]{
+			Arg:    one.Arg,
+			Res:    one.Res,
+			Erx:    one.Erx,
+			Status: one.Status,
+			Rounds: one.Rounds,
+		})
+	}
+	erx := tasks.Retry(ctx, runner)
+	for idx, task := range tasks {
+		rs[idx].Res = task.Res
+		rs[idx].Erx = task.Erx
+		rs[idx].Status = task.Status
+		rs[idx].Rounds = task.Rounds
+	}
+	return erx
+}
@@ -0,0 +1,88 @@
+package egobatch_test
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestTasks_Retry(t *testing.T) {
+	// Argument N fails until its N-th round
+	// 参数 N 在第 N 轮之前一直失败
+	var rounds [4]atomic.Int32
+	run := func(ctx context.Context, arg int) (string, *myerrors.Error) {
+		if int(rounds[arg].Add(1)) <= arg {
+			return "", myerrors.ErrorServiceError("wrong-%d", arg)
+		}
+		return "ok-" + strconv.Itoa(arg), nil
+	}
+	tasks, erx := egobatch.NewRunner(run).WithGlide(true).Run(context.Background(), []int{0, 1, 2, 3})
+	myassert.NoError(t, erx)
+	require.Len(t, tasks.WaTasks(), 3)
+
+	for range 3 {
+		myassert.NoError(t, tasks.Retry(context.Background(), egobatch.NewRunner(run).WithGlide(true).WithLimit(2)))
+	}
+	require.Len(t, tasks.WaTasks(), 0)
+	for idx, task := range tasks {
+		require.Equal(t, egobatch.TaskStatusSucceeded, task.Status)
+		require.Equal(t, "ok-"+strconv.Itoa(idx), task.Res)
+		require.Equal(t, int32(idx+1), rounds[idx].Load())
+	}
+
+	// Successful task never retried, others retried until round arg succeeded
+	// 成功的任务从未重试，其他任务重试直到第 arg 轮成功
+	require.Empty(t, tasks[0].Rounds)
+	for idx := 1; idx < len(tasks); idx++ {
+		task := tasks[idx]
+		require.Len(t, task.Rounds, idx+1)
+		for round, one := range task.Rounds[:idx] {
+			require.Equal(t, egobatch.TaskStatusFailed, one.Status, round)
+			require.NotNil(t, one.Erx)
+		}
+		require.Equal(t, egobatch.TaskStatusSucceeded, task.Rounds[idx].Status)
+		require.Nil(t, task.Rounds[idx].Erx)
+	}
+}
+
+func TestTasks_Retry_Unexecuted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	run := func(ctx context.Context, arg int) (int, *myerrors.Error) {
+		return arg * 2, nil
+	}
+	tasks, erx := egobatch.Run(ctx, []int{1, 2}, run)
+	myassert.NoError(t, erx)
+	require.Len(t, tasks.Skipped(), 2)
+
+	myassert.NoError(t, tasks.Retry(context.Background(), egobatch.NewRunner(run)))
+	require.Equal(t, []int{2, 4}, tasks.Flatten(func(arg int, erx *myerrors.Error) int { return -1 }))
+	require.Equal(t, egobatch.TaskStatusSkipped, tasks[0].Rounds[0].Status)
+	require.Equal(t, egobatch.TaskStatusSucceeded, tasks[0].Rounds[1].Status)
+}
+
+func TestTaskOutputList_Rerun(t *testing.T) {
+	outputs := egobatch.TaskOutputList[int, string, *myerrors.Error]{
+		egobatch.NewOkTaskOutput[int, string, *myerrors.Error](0, "ok-0"),
+		egobatch.NewWaTaskOutput[int, string](1, myerrors.ErrorServiceError("wrong-1")),
+		egobatch.NewNoTaskOutput[int, string, *myerrors.Error](2, egobatch.TaskStatusSkipped),
+	}
+	var runs atomic.Int32
+	erx := outputs.Rerun(context.Background(), egobatch.NewRunner(func(ctx context.Context, arg int) (string, *myerrors.Error) {
+		runs.Add(1)
+		return "ok-" + strconv.Itoa(arg), nil
+	}))
+	myassert.NoError(t, erx)
+	require.Equal(t, int32(2), runs.Load())
+	require.Equal(t, []string{"ok-0", "ok-1", "ok-2"}, outputs.OkResults())
+	require.Empty(t, outputs[0].Rounds)
+	require.Len(t, outputs[1].Rounds, 2)
+	require.Equal(t, egobatch.TaskStatusFailed, outputs[1].Rounds[0].Status)
+	require.Equal(t, egobatch.TaskStatusSucceeded, outputs[1].Rounds[1].Status)
+}
@@ -0,0 +1,80 @@
+package egobatch
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy configures per-task retry with exponential backoff and jitter
+// Errors accepted by Retryable get retried until MaxAttempts, just the final error gets stored
+//
+// RetryPolicy 配置单任务重试，支持指数退避和随机抖动
+// 被 Retryable 接受的错误会重试直到 MaxAttempts，仅保存最终错误
+type RetryPolicy[E ErrorType] struct {
+	MaxAttempts int              // Max run invocations including first one // 最大调用次数（包含首次）
+	BaseDelay   time.Duration    // Delay before second attempt // 第二次尝试前的延迟
+	MaxDelay    time.Duration    // Delay upper bound, zero means no bound // 延迟上限，零表示不限制
+	Multiplier  float64          // Backoff growth factor, values below 1 treated as 2 // 退避增长因子，小于 1 时按 2 处理
+	Jitter      float64          // Random reduction ratio in [0, 1] applied on each delay // 每次延迟的随机缩减比例，范围 [0, 1]
+	Retryable   func(erx E) bool // Classifier deciding retryable errors, nil means retry all // 判断错误是否可重试，nil 表示全部重试
+}
+
+// NewRetryPolicy creates retry policy with max attempts and base delay
+// Uses multiplier 2 and no jitter, fields can be adjusted afterwards
+//
+// NewRetryPolicy 使用最大次数和基础延迟创建重试策略
+// 使用倍数 2 且无抖动，字段可在创建后调整
+func NewRetryPolicy[E ErrorType](maxAttempts int, baseDelay time.Duration) *RetryPolicy[E] {
+	return &RetryPolicy[E]{
+		MaxAttempts: maxAttempts,
+		BaseDelay:   baseDelay,
+		Multiplier:  2,
+	}
+}
+
+// canRetry checks if another attempt is allowed after given attempt failed with erx
+// canRetry 检查在第 attempt 次以 erx 失败后是否允许再次尝试
+func (p *RetryPolicy[E]) canRetry(attempt int, erx E) bool {
+	if attempt >= p.MaxAttempts {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(erx)
+}
+
+// backoff computes delay after given attempt with exponential growth, bound and jitter
+// backoff 计算第 attempt 次之后的延迟，包含指数增长、上限和抖动
+func (p *RetryPolicy[E]) backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+	delay := float64(p.BaseDelay) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay -= delay * min(p.Jitter, 1) * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// sleepCtx waits given duration unless context finishes first
+// Returns false when context finishes before duration elapses
+//
+// sleepCtx 等待给定时长，除非上下文先结束
+// 当上下文在时长结束前结束时返回 false
+func sleepCtx(ctx context.Context, duration time.Duration) bool {
+	if duration <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,67 @@
+package egobatch_test
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestTaskBatch_SetRetry(t *testing.T) {
+	var args = []uint64{0, 1, 2, 3}
+	taskBatch := egobatch.NewTaskBatch[uint64, string, *myerrors.Error](args)
+	taskBatch.SetGlide(true)
+	retry := egobatch.NewRetryPolicy[*myerrors.Error](3, time.Millisecond)
+	retry.Jitter = 0.5
+	retry.Retryable = func(erx *myerrors.Error) bool {
+		return myerrors.IsServiceError(erx)
+	}
+	taskBatch.SetRetry(retry)
+
+	var counts [4]atomic.Int32
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		count := counts[arg].Add(1)
+		switch arg {
+		case 1: // flaky db: success on second attempt
+			if count < 2 {
+				return "", myerrors.ErrorServiceError("wrong-db")
+			}
+		case 2: // always wrong db: retried until max attempts
+			return "", myerrors.ErrorServiceError("wrong-db-%d", count)
+		case 3: // not retryable
+			return "", myerrors.New("BAD_PARAM", "bad param")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	})
+	myassert.NoError(t, ego.Wait())
+
+	require.Equal(t, 1, taskBatch.Tasks[0].Attempts)
+	require.Equal(t, 2, taskBatch.Tasks[1].Attempts)
+	require.Equal(t, "1", taskBatch.Tasks[1].Res)
+	require.Equal(t, egobatch.TaskStatusSucceeded, taskBatch.Tasks[1].Status)
+	require.Equal(t, 3, taskBatch.Tasks[2].Attempts)
+	require.Contains(t, taskBatch.Tasks[2].Erx.Error(), "wrong-db-3") // just the final error gets stored
+	require.Equal(t, 1, taskBatch.Tasks[3].Attempts)
+	require.Equal(t, "BAD_PARAM", taskBatch.Tasks[3].Erx.Code())
+}
+
+func TestTaskBatch_SetRetry_ContextDone(t *testing.T) {
+	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancelFunc()
+
+	tasks, erx := egobatch.NewRunner(func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		return "", myerrors.ErrorServiceError("wrong-db")
+	}).WithGlide(true).WithRetry(egobatch.NewRetryPolicy[*myerrors.Error](10, time.Hour)).Run(ctx, []uint64{0})
+	myassert.NoError(t, erx)
+
+	require.Equal(t, 1, tasks[0].Attempts) // backoff sleep interrupted by context
+	require.True(t, myerrors.IsServiceError(tasks[0].Erx))
+}
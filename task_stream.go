@@ -0,0 +1,147 @@
+package egobatch
+
+import (
+	"context"
+	"iter"
+	"sync"
+	"sync/atomic"
+
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/must"
+)
+
+// EgoStream schedules tasks into the provided errgroup and yields each task in completion order
+// Yields task index with task once its outcome is settled (success, failure, cancelled or skipped)
+// Buffer bounds completed tasks awaiting consumption, when full workers block, throttling the batch
+// Sequence ends after every task is yielded, then invoking ego.Wait returns without blocking on tasks
+// Breaking early stops publishing and returns right away, remaining tasks still get scheduled and run
+// ego.Wait is what waits for the rest, including tasks not yet scheduled at break
+// Sequence must be consumed, else workers block on publishing forever
+// Panics on lazy batch, which needs EgoSink
+//
+// EgoStream 将任务调度到提供的 errgroup 中，并按完成顺序产出每个任务
+// 任务结果确定后（成功、失败、取消或跳过）产出任务序号和任务
+// buffer 限制等待消费的已完成任务数量，满时工作协程阻塞，从而限制批量速度
+// 所有任务产出后序列结束，此时调用 ego.Wait 不会再等待任务
+// 提前中断会停止发布并立即返回，剩余任务仍会被调度和执行
+// 由 ego.Wait 等待剩余任务，包括中断时尚未调度的任务
+// 序列必须被消费，否则工作协程会一直阻塞在发布上
+// 对惰性批量会 panic，惰性批量需使用 EgoSink
+func (t *TaskBatch[A, R, E]) EgoStream(ego *erxgroup.Group[E], run func(ctx context.Context, arg A) (R, E), buffer int) iter.Seq2[int, *Task[A, R, E]] {
+	must.True(t.lazyArgs == nil) // Lazy batch keeps Tasks empty, schedule it with EgoSink // 惰性批量的 Tasks 为空，需使用 EgoSink 调度
+	if len(t.Tasks) == 0 {
+		return func(yield func(int, *Task[A, R, E]) bool) {}
+	}
+	completed := make(chan int, max(buffer, 0))
+	stopped := make(chan struct{})
+	var stopOnce sync.Once
+
+	var remaining atomic.Int64
+	remaining.Store(int64(len(t.Tasks)))
+	publish := func(idx int) {
+		select {
+		case completed <- idx:
+		case <-stopped: // Consumer broke early: drop without blocking // 消费者提前中断：直接丢弃不阻塞
+		}
+		if remaining.Add(-1) == 0 {
+			close(completed) // Last publisher closes after every send finished // 最后一个发布者在所有发送完成后关闭
+		}
+	}
+
+	release := ego.Hold() // Taken before returning, so ego.Wait covers tasks scheduled after break // 在返回前获取，使 ego.Wait 覆盖中断后调度的任务
+	go func() {
+		defer release()
+		t.dispatch(t.order(), func(idx int, release func()) {
+			t.egoGoKeyed(ego, t.Tasks[idx], run, release, func(taskRun func(ctx context.Context) E) func(ctx context.Context) E {
+				return func(ctx context.Context) E {
+					defer publish(idx)
+					return taskRun(ctx)
+				}
+			})
+		})
+	}()
+
+	return func(yield func(int, *Task[A, R, E]) bool) {
+		for idx := range completed {
+			if !yield(idx, t.Tasks[idx]) {
+				stopOnce.Do(func() { close(stopped) })
+				return
+			}
+		}
+	}
+}
+
+// EgoStreamOrdered schedules tasks into the provided errgroup and yields tasks in argument order
+// Each task gets yielded as soon as it and every earlier task have settled outcome
+// Window bounds scheduled but not yet yielded tasks, a slow head task throttles scheduling
+// Sequence must be consumed, breaking early returns right away like EgoStream, ego.Wait waits for the rest
+// Panics on lazy batch, which needs EgoSink
+//
+// EgoStreamOrdered 将任务调度到提供的 errgroup 中，并按参数顺序产出任务
+// 当任务及其之前的所有任务都已确定结果时立即产出
+// window 限制已调度但尚未产出的任务数量，较慢的头部任务会限制调度速度
+// 序列必须被消费，提前中断与 EgoStream 一样立即返回，由 ego.Wait 等待剩余任务
+// 对惰性批量会 panic，惰性批量需使用 EgoSink
+func (t *TaskBatch[A, R, E]) EgoStreamOrdered(ego *erxgroup.Group[E], run func(ctx context.Context, arg A) (R, E), window int) iter.Seq2[int, *Task[A, R, E]] {
+	must.True(t.lazyArgs == nil) // Lazy batch keeps Tasks empty, schedule it with EgoSink // 惰性批量的 Tasks 为空，需使用 EgoSink 调度
+	if len(t.Tasks) == 0 {
+		return func(yield func(int, *Task[A, R, E]) bool) {}
+	}
+	window = max(window, 1)
+	slots := make(chan struct{}, window) // Reorder buffer slots // 重排缓冲槽位
+	completed := make(chan int, window)  // Never blocks: at most window tasks hold slots // 不会阻塞：最多 window 个任务持有槽位
+	stopped := make(chan struct{})
+	var stopOnce sync.Once
+
+	release := ego.Hold() // Taken before returning, so ego.Wait covers tasks scheduled after break // 在返回前获取，使 ego.Wait 覆盖中断后调度的任务
+	go func() {
+		defer release()
+		for idx := 0; idx < len(t.Tasks); idx++ {
+			select {
+			case slots <- struct{}{}: // Acquire slot, released when task gets yielded in order // 获取槽位，任务按序产出时释放
+			case <-stopped:
+			}
+			t.egoGoKeyed(ego, t.Tasks[idx], run, t.holdKey(t.Tasks[idx]), func(taskRun func(ctx context.Context) E) func(ctx context.Context) E {
+				return func(ctx context.Context) E {
+					defer func() {
+						select {
+						case completed <- idx:
+						case <-stopped:
+						}
+					}()
+					return taskRun(ctx)
+				}
+			})
+		}
+	}()
+
+	return func(yield func(int, *Task[A, R, E]) bool) {
+		settled := make(map[int]bool, window)
+		for next := 0; next < len(t.Tasks); {
+			settled[<-completed] = true
+			for ; settled[next]; next++ {
+				delete(settled, next)
+				<-slots // Release slot letting scheduler spawn next task // 释放槽位使调度器启动下一个任务
+				if !yield(next, t.Tasks[next]) {
+					stopOnce.Do(func() { close(stopped) })
+					return
+				}
+			}
+		}
+	}
+}
+
+// OutputSeq adapts task sequence into task output sequence
+// Works with EgoStream and EgoStreamOrdered when downstream consumes TaskOutput
+//
+// OutputSeq 将任务序列适配为任务输出序列
+// 当下游消费 TaskOutput 时可与 EgoStream 和 EgoStreamOrdered 配合使用
+func OutputSeq[A any, R any, E ErrorType](seq iter.Seq2[int, *Task[A, R, E]]) iter.Seq2[int, *TaskOutput[A, R, E]] {
+	return func(yield func(int, *TaskOutput[A, R, E]) bool) {
+		for idx, task := range seq {
+			if !yield(idx, task.Output()) {
+				return
+			}
+		}
+	}
+}
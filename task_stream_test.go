@@ -0,0 +1,144 @@
+package egobatch_test
+
+import (
+	"context"
+	"iter"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestTaskBatch_EgoStream(t *testing.T) {
+	var args = []uint64{5, 1, 3, 0, 4, 2}
+	taskBatch := egobatch.NewTaskBatch[uint64, string, *myerrors.Error](args)
+	taskBatch.SetGlide(true)
+
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	var sequence []uint64
+	var okCount int
+	for idx, task := range taskBatch.EgoStream(ego, func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		time.Sleep(time.Duration(arg) * time.Millisecond * 20)
+		if arg == 3 {
+			return "", myerrors.ErrorServiceError("wrong-db")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	}, 0) {
+		t.Log("idx:", idx, "arg:", task.Arg, "res:", task.Res, "erx:", task.Erx)
+		require.Equal(t, args[idx], task.Arg)
+		require.NotEqual(t, egobatch.TaskStatusRunning, task.Status)
+		sequence = append(sequence, task.Arg)
+		if task.Status == egobatch.TaskStatusSucceeded {
+			okCount++
+		}
+	}
+	myassert.NoError(t, ego.Wait())
+	require.Equal(t, []uint64{0, 1, 2, 3, 4, 5}, sequence) // completion order
+	require.Equal(t, 5, okCount)
+}
+
+func TestTaskBatch_EgoStream_Break(t *testing.T) {
+	args := make([]uint64, 0, 20)
+	for num := uint64(0); num < 20; num++ {
+		args = append(args, num)
+	}
+	taskBatch := egobatch.NewTaskBatch[uint64, string, *myerrors.Error](args)
+
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(2)
+	var count int
+	for range taskBatch.EgoStream(ego, func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		return strconv.FormatUint(arg, 10), nil
+	}, 1) {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	myassert.NoError(t, ego.Wait())
+	require.Equal(t, 3, count)
+	require.Len(t, taskBatch.Tasks.OkTasks(), 20) // remaining tasks still run
+}
+
+func TestTaskBatch_EgoStream_BreakReturns(t *testing.T) {
+	args := make([]uint64, 0, 10)
+	for num := uint64(0); num < 10; num++ {
+		args = append(args, num)
+	}
+	gate := make(chan struct{})
+	run := func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg > 0 {
+			<-gate // Remaining tasks block until the consumer is back from break // 剩余任务阻塞直到消费者从中断返回
+		}
+		return strconv.FormatUint(arg, 10), nil
+	}
+
+	for _, ordered := range []bool{false, true} {
+		taskBatch := egobatch.NewTaskBatch[uint64, string, *myerrors.Error](args)
+		ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+		ego.SetLimit(1)
+		gate = make(chan struct{})
+		var seq iter.Seq2[int, *egobatch.Task[uint64, string, *myerrors.Error]]
+		if ordered {
+			seq = taskBatch.EgoStreamOrdered(ego, run, 1)
+		} else {
+			seq = taskBatch.EgoStream(ego, run, 1)
+		}
+		timer := time.AfterFunc(time.Second, func() { close(gate) }) // Fallback when break blocks // 中断阻塞时的兜底
+		startTime := time.Now()
+		for idx := range seq {
+			require.Equal(t, 0, idx)
+			break
+		}
+		require.Less(t, time.Since(startTime), 500*time.Millisecond, ordered)
+		require.True(t, timer.Stop())
+		close(gate)
+
+		myassert.NoError(t, ego.Wait())
+		require.Len(t, taskBatch.Tasks.OkTasks(), 10, ordered) // ego.Wait waits for the rest
+	}
+}
+
+func TestTaskBatch_EgoStreamOrdered(t *testing.T) {
+	args := make([]uint64, 0, 10)
+	for num := uint64(0); num < 10; num++ {
+		args = append(args, num)
+	}
+	taskBatch := egobatch.NewTaskBatch[uint64, string, *myerrors.Error](args)
+	taskBatch.SetGlide(true)
+
+	const window = 3
+	var started atomic.Int64
+	var yielded int64
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	seq := taskBatch.EgoStreamOrdered(ego, func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		started.Add(1)
+		if arg%4 == 0 {
+			time.Sleep(time.Millisecond * 30) // slow head task throttles scheduling
+		}
+		if arg == 5 {
+			return "", myerrors.ErrorServiceError("wrong-db")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	}, window)
+
+	outputs := egobatch.TaskOutputList[uint64, string, *myerrors.Error]{}
+	for idx, output := range egobatch.OutputSeq(seq) {
+		require.Equal(t, int(yielded), idx) // argument order
+		require.LessOrEqual(t, started.Load()-yielded, int64(window))
+		yielded++
+		outputs = append(outputs, output)
+	}
+	myassert.NoError(t, ego.Wait())
+
+	require.Len(t, outputs, 10)
+	require.Equal(t, 9, outputs.OkCount())
+	require.Equal(t, 1, outputs.WaCount())
+	require.Equal(t, taskBatch.Tasks.Outputs(), outputs)
+}
@@ -58,3 +58,26 @@ func TestTasks_OkTasks(t *testing.T) {
 		require.Equal(t, []string{"0", "wa-1", "2", "wa-3", "4", "wa-5", "6", "wa-7", "8", "wa-9"}, results)
 	})
 }
+
+func TestTasks_FlattenWaNo(t *testing.T) {
+	var tasks = egobatch.Tasks[uint64, string, *myerrors.Error]{
+		{Arg: 0, Res: "0", Status: egobatch.TaskStatusSucceeded},
+		{Arg: 1, Erx: myerrors.ErrorServiceError("wrong-db"), Status: egobatch.TaskStatusFailed},
+		{Arg: 2, Status: egobatch.TaskStatusSkipped},
+		{Arg: 3, Status: egobatch.TaskStatusPending},
+	}
+	require.Len(t, tasks.OkTasks(), 1)
+	require.Len(t, tasks.WaTasks(), 1)
+	require.Len(t, tasks.NoTasks(), 2)
+	require.Len(t, tasks.Skipped(), 1)
+	require.Len(t, tasks.Pending(), 1)
+	require.Len(t, tasks.Cancelled(), 0)
+
+	results := tasks.FlattenWaNo(func(arg uint64, erk *myerrors.Error) string {
+		return "wa-" + strconv.FormatUint(arg, 10)
+	}, func(arg uint64, status egobatch.TaskStatus) string {
+		return "no-" + strconv.FormatUint(arg, 10) + "-" + string(status)
+	})
+	t.Log(neatjsons.S(results))
+	require.Equal(t, []string{"0", "wa-1", "no-2-SKIPPED", "no-3-PENDING"}, results)
+}
@@ -0,0 +1,64 @@
+package egobatch
+
+import "sync/atomic"
+
+// Threshold configures failure tolerance between glide mode and fail-fast mode
+// Failures get recorded without canceling until threshold gets crossed, then batch aborts
+// Both bounds can be combined, crossing either one aborts the batch
+//
+// Threshold 配置介于平滑模式和快速失败模式之间的失败容忍度
+// 在越过阈值前记录失败但不取消，越过后批量中止
+// 两种上限可以组合使用，越过任意一个即中止批量
+type Threshold struct {
+	MaxFailures  int     // Abort once failures exceed this count, non-positive means no count bound // 失败数超过该值时中止，非正数表示不限制数量
+	MaxFailRate  float64 // Abort once failure rate exceeds this ratio, non-positive means no rate bound // 失败率超过该比例时中止，非正数表示不限制比例
+	MinCompleted int     // Completions needed before failure rate gets checked // 检查失败率前需要的完成数量
+}
+
+// NewMaxFailuresThreshold creates threshold tolerating up to maxFailures failures
+// NewMaxFailuresThreshold 创建最多容忍 maxFailures 个失败的阈值
+func NewMaxFailuresThreshold(maxFailures int) *Threshold {
+	return &Threshold{MaxFailures: maxFailures}
+}
+
+// NewMaxFailRateThreshold creates threshold aborting once failure rate exceeds maxFailRate after minCompleted completions
+// NewMaxFailRateThreshold 创建在完成 minCompleted 个后失败率超过 maxFailRate 即中止的阈值
+func NewMaxFailRateThreshold(maxFailRate float64, minCompleted int) *Threshold {
+	return &Threshold{MaxFailRate: maxFailRate, MinCompleted: minCompleted}
+}
+
+// crossed checks if given failed and completed counts cross the threshold
+// crossed 检查给定的失败数和完成数是否越过阈值
+func (h *Threshold) crossed(failed int64, completed int64) bool {
+	if h.MaxFailures > 0 && failed > int64(h.MaxFailures) {
+		return true
+	}
+	if h.MaxFailRate > 0 && completed > 0 && completed >= int64(h.MinCompleted) {
+		return float64(failed)/float64(completed) > h.MaxFailRate
+	}
+	return false
+}
+
+// thresholdCounter tracks completions and failures across GetRun closures with atomic counters
+// thresholdCounter 使用原子计数器跨 GetRun 闭包跟踪完成数和失败数
+type thresholdCounter struct {
+	completed atomic.Int64 // Tasks that ran to success or failure // 执行到成功或失败的任务数
+	failed    atomic.Int64 // Tasks that ran to failure // 执行失败的任务数
+	aborted   atomic.Bool  // Threshold crossed flag // 已越过阈值标志
+}
+
+// record counts one completion and reports whether threshold is crossed now or before
+// record 记录一次完成并报告当前或之前是否已越过阈值
+func (c *thresholdCounter) record(threshold *Threshold, fail bool) bool {
+	var failed int64
+	if fail {
+		failed = c.failed.Add(1)
+	} else {
+		failed = c.failed.Load()
+	}
+	completed := c.completed.Add(1)
+	if threshold.crossed(failed, completed) {
+		c.aborted.Store(true)
+	}
+	return c.aborted.Load()
+}
@@ -0,0 +1,72 @@
+package egobatch_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestTaskBatch_SetThreshold_MaxFailures(t *testing.T) {
+	args := make([]uint64, 0, 20)
+	for num := uint64(0); num < 20; num++ {
+		args = append(args, num)
+	}
+	taskBatch := egobatch.NewTaskBatch[uint64, string, *myerrors.Error](args)
+	taskBatch.SetThreshold(egobatch.NewMaxFailuresThreshold(2))
+	taskBatch.SetWaCtx(func(err error) *myerrors.Error {
+		return myerrors.ErrorWrongContext("wrong-ctx. error=%v", err)
+	})
+
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(1)
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg%3 == 0 {
+			return "", myerrors.ErrorServiceError("wrong-db")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	})
+	erx := ego.Wait()
+	myassert.Error(t, erx)
+	require.True(t, myerrors.IsServiceError(erx))
+	require.True(t, taskBatch.Aborted())
+
+	// args 0, 3 tolerated, arg 6 crosses threshold, args after 6 get cancelled
+	require.Len(t, taskBatch.Tasks.OkTasks(), 4)
+	require.Len(t, taskBatch.Tasks.Cancelled(), 13)
+	for _, task := range taskBatch.Tasks.Cancelled() {
+		require.True(t, myerrors.IsWrongContext(task.Erx))
+		require.Greater(t, task.Arg, uint64(6))
+	}
+}
+
+func TestRun_WithThreshold_MaxFailRate(t *testing.T) {
+	args := make([]uint64, 0, 20)
+	for num := uint64(0); num < 20; num++ {
+		args = append(args, num)
+	}
+	run := func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg%4 == 3 {
+			return "", myerrors.ErrorServiceError("wrong-db")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	}
+
+	t.Run("tolerated", func(t *testing.T) {
+		tasks, erx := egobatch.NewRunner(run).WithLimit(1).WithThreshold(egobatch.NewMaxFailRateThreshold(0.3, 5)).Run(context.Background(), args)
+		myassert.NoError(t, erx)
+		require.Len(t, tasks.WaTasks(), 5)
+		require.Len(t, tasks.OkTasks(), 15)
+	})
+
+	t.Run("aborted", func(t *testing.T) {
+		tasks, erx := egobatch.NewRunner(run).WithLimit(1).WithThreshold(egobatch.NewMaxFailRateThreshold(0.2, 5)).Run(context.Background(), args)
+		myassert.Error(t, erx)
+		require.NotEmpty(t, tasks.Skipped())
+	})
+}
@@ -0,0 +1,97 @@
+package egobatch_test
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestTaskBatch_SetTaskTimeout(t *testing.T) {
+	var args = []uint64{0, 1, 2, 3}
+	taskBatch := egobatch.NewTaskBatch[uint64, string, *myerrors.Error](args)
+	taskBatch.SetGlide(true)
+	taskBatch.SetTaskTimeout(time.Millisecond * 50)
+	taskBatch.SetWaCtx(func(err error) *myerrors.Error {
+		return myerrors.ErrorWrongContext("wrong-ctx. error=%v", err)
+	})
+
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(1) // tasks after the hung one still run, batch context not cancelled
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg == 1 { // hung task: blocks until own deadline
+			<-ctx.Done()
+			return "", myerrors.ErrorServiceError("hung")
+		}
+		time.Sleep(time.Millisecond * 10)
+		if ctx.Err() != nil {
+			return "", myerrors.ErrorServiceError("unexpected")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	})
+	myassert.NoError(t, ego.Wait())
+
+	for idx, task := range taskBatch.Tasks {
+		t.Log("idx:", idx, "arg:", task.Arg, "res:", task.Res, "erx:", task.Erx)
+		if idx == 1 {
+			require.True(t, myerrors.IsWrongContext(task.Erx))
+			require.Equal(t, egobatch.TaskStatusFailed, task.Status)
+		} else {
+			myassert.NoError(t, task.Erx)
+		}
+	}
+}
+
+func TestRun_WithTaskTimeout(t *testing.T) {
+	tasks, erx := egobatch.NewRunner(func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if arg == 0 {
+			<-ctx.Done()
+			return "", myerrors.ErrorServiceError("hung")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	}).WithGlide(true).WithTaskTimeout(time.Millisecond*20).Run(context.Background(), []uint64{0, 1})
+	myassert.NoError(t, erx)
+
+	require.True(t, myerrors.IsServiceError(tasks[0].Erx)) // no waCtx: error from run is kept
+	require.Equal(t, "1", tasks[1].Res)
+}
+
+func TestTaskBatch_SetTaskTimeout_WithRetry(t *testing.T) {
+	taskBatch := egobatch.NewTaskBatch[uint64, string, *myerrors.Error]([]uint64{0})
+	taskBatch.SetGlide(true)
+	taskBatch.SetTaskTimeout(time.Millisecond * 30)
+	taskBatch.SetWaCtx(func(err error) *myerrors.Error {
+		return myerrors.ErrorWrongContext("wrong-ctx. error=%v", err)
+	})
+	retry := egobatch.NewRetryPolicy[*myerrors.Error](3, time.Millisecond)
+	retry.Retryable = func(erx *myerrors.Error) bool {
+		return myerrors.IsWrongContext(erx) // timeouts are transient // 超时是暂时性的
+	}
+	taskBatch.SetRetry(retry)
+
+	var attempts atomic.Int32
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg uint64) (string, *myerrors.Error) {
+		if attempts.Add(1) < 3 { // hangs on first two attempts until own deadline
+			<-ctx.Done()
+			return "", myerrors.ErrorServiceError("hung")
+		}
+		if ctx.Err() != nil {
+			return "", myerrors.ErrorServiceError("unexpected")
+		}
+		return strconv.FormatUint(arg, 10), nil
+	})
+	myassert.NoError(t, ego.Wait())
+
+	task := taskBatch.Tasks[0]
+	require.Equal(t, egobatch.TaskStatusSucceeded, task.Status) // each attempt got a fresh timeout
+	require.Equal(t, "0", task.Res)
+	require.Equal(t, 3, task.Attempts)
+}
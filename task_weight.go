@@ -0,0 +1,48 @@
+package egobatch
+
+import (
+	"context"
+
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/constraint"
+	"github.com/yyle88/must"
+)
+
+// SetWeight configures task weight held on group capacity while the task runs
+// Group limit then caps total weight in flight instead of goroutine count, see erxgroup.Group.GoWeighted
+// Task heavier than capacity fails with waWeight converting *erxgroup.WeightError, instead of blocking forever
+// Applies to EgoRun, EgoStream, EgoStreamOrdered and EgoSink scheduling
+//
+// SetWeight 配置任务执行期间占用 group 容量的权重
+// 此时 group 限制的是运行中的总权重而不是协程数量，参见 erxgroup.Group.GoWeighted
+// 权重超过容量的任务以 waWeight 转换的 *erxgroup.WeightError 失败，而不是永久阻塞
+// 作用于 EgoRun、EgoStream、EgoStreamOrdered 和 EgoSink 调度
+func (t *TaskBatch[A, R, E]) SetWeight(weight func(arg A) int64, waWeight func(err error) E) {
+	must.True(weight == nil || waWeight != nil) // Oversize tasks need converter // 超限任务需要转换函数
+	t.weight = weight
+	t.waWeight = waWeight
+}
+
+// egoGo schedules task closure into group, holding task weight when configured
+// Wrap when not nil decorates the scheduled closure, oversize failure closure included
+//
+// egoGo 将任务闭包调度到 group 中，配置权重时占用任务权重
+// wrap 不为 nil 时装饰被调度的闭包，包括超限失败的闭包
+func (t *TaskBatch[A, R, E]) egoGo(ego *erxgroup.Group[E], task *Task[A, R, E], taskRun func(ctx context.Context) E, wrap func(taskRun func(ctx context.Context) E) func(ctx context.Context) E) {
+	if wrap == nil {
+		wrap = func(taskRun func(ctx context.Context) E) func(ctx context.Context) E { return taskRun }
+	}
+	if t.weight == nil {
+		ego.Go(wrap(taskRun))
+		return
+	}
+	err := ego.GoWeighted(t.weight(task.Arg), wrap(taskRun))
+	if err == nil {
+		return
+	}
+	erx := t.waWeight(err) // Convert weight error - must return valid error, not fake zero // 转换权重错误 - 必须返回有效错误，不能是伪造的零值
+	must.False(constraint.Pass(erx))
+	must.Done(ego.GoWeighted(0, wrap(t.settleRun(task, func(ctx context.Context) E {
+		return t.failRun(ctx, task, erx) // Zero weight: report failure through group without taking capacity // 零权重：不占用容量，通过 group 报告失败
+	}))))
+}
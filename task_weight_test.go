@@ -0,0 +1,117 @@
+package egobatch_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yyle88/egobatch"
+	"github.com/yyle88/egobatch/erxgroup"
+	"github.com/yyle88/egobatch/internal/myassert"
+	"github.com/yyle88/egobatch/internal/myerrors"
+)
+
+func TestTaskBatch_SetWeight(t *testing.T) {
+	// Argument is its own weight: 50 is a big report heavier than capacity
+	// 参数即为权重：50 是超过容量的大报表
+	args := []int64{4, 1, 1, 6, 2, 50, 3, 5}
+	taskBatch := egobatch.NewTaskBatch[int64, int64, *myerrors.Error](args)
+	taskBatch.SetGlide(true)
+	taskBatch.SetWeight(func(arg int64) int64 {
+		return arg
+	}, func(err error) *myerrors.Error {
+		return myerrors.ErrorWrongContext("oversize: %s", err.Error())
+	})
+
+	var inFlight, peak atomic.Int64
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(8)
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg int64) (int64, *myerrors.Error) {
+		current := inFlight.Add(arg)
+		for {
+			value := peak.Load()
+			if current <= value || peak.CompareAndSwap(value, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		inFlight.Add(-arg)
+		return arg * 10, nil
+	})
+	myassert.NoError(t, ego.Wait())
+	t.Log(peak.Load())
+	require.LessOrEqual(t, peak.Load(), int64(8))
+
+	require.Len(t, taskBatch.Tasks.OkTasks(), len(args)-1)
+	waTasks := taskBatch.Tasks.WaTasks()
+	require.Len(t, waTasks, 1)
+	require.Equal(t, int64(50), waTasks[0].Arg)
+	require.Equal(t, egobatch.TaskStatusFailed, waTasks[0].Status)
+	require.Contains(t, waTasks[0].Erx.Error(), "weight 50 exceeds capacity 8")
+}
+
+func TestRun_WithWeight(t *testing.T) {
+	args := []int64{3, 9, 2}
+	tasks, erx := egobatch.NewRunner(func(ctx context.Context, arg int64) (int64, *myerrors.Error) {
+		return arg, nil
+	}).WithLimit(5).WithWeight(func(arg int64) int64 {
+		return arg
+	}, func(err error) *myerrors.Error {
+		return myerrors.ErrorWrongContext("oversize: %s", err.Error())
+	}).Run(context.Background(), args)
+	// Fail-fast: oversize task returns its error to the group
+	// 快速失败：超限任务将错误返回给 group
+	require.NotNil(t, erx)
+	require.Contains(t, erx.Error(), "weight 9 exceeds capacity 5")
+	require.Equal(t, egobatch.TaskStatusFailed, tasks[1].Status)
+}
+
+func TestTaskBatch_SetWeight_OversizeSettled(t *testing.T) {
+	// Oversize failure settles like run failures: outcome node and checkpoint line get written
+	// 超限失败与 run 失败一样确定结果：写入结果树节点和检查点行
+	path := filepath.Join(t.TempDir(), "tasks.jsonl")
+	checkpoint, err := egobatch.OpenCheckpoint(path, newCheckpointCodecs())
+	require.NoError(t, err)
+	taskBatch, err := egobatch.NewTaskBatchResume([]int{2, 50, 3}, checkpoint)
+	require.NoError(t, err)
+	taskBatch.SetGlide(true)
+	taskBatch.SetWeight(func(arg int) int64 {
+		return int64(arg)
+	}, func(err error) *myerrors.Error {
+		return myerrors.ErrorWrongContext("oversize: %s", err.Error())
+	})
+	root := egobatch.NewOutcomeTree("reports")
+	taskBatch.SetOutcome(root, func(arg int) string {
+		return "report-" + strconv.Itoa(arg)
+	})
+
+	ego := erxgroup.NewGroup[*myerrors.Error](context.Background())
+	ego.SetLimit(8)
+	taskBatch.EgoRun(ego, func(ctx context.Context, arg int) (string, *myerrors.Error) {
+		return "ok-" + strconv.Itoa(arg), nil
+	})
+	myassert.NoError(t, ego.Wait())
+	require.NoError(t, checkpoint.Close())
+
+	node := root.Children()[1]
+	require.Equal(t, "report-50", node.Name)
+	require.Equal(t, egobatch.TaskStatusFailed, node.Status())
+	require.ErrorContains(t, node.Erx(), "weight 50 exceeds capacity 8")
+	rollup := root.Rollup()
+	require.Equal(t, 2, rollup.Ok)
+	require.Equal(t, 1, rollup.Wa)
+	require.Equal(t, 0, rollup.Pending)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(content), `"key":"50","status":"FAILED"`)
+	checkpoint, err = egobatch.OpenCheckpoint(path, newCheckpointCodecs())
+	require.NoError(t, err)
+	require.Equal(t, 2, checkpoint.Completed())
+	require.NoError(t, checkpoint.Close())
+}